@@ -25,6 +25,12 @@ type ReceiptDataResponse struct {
 	Date     string `json:"date"`
 }
 
+type ReceiptItemsResponse struct {
+	Merchant string `json:"merchant"`
+	Date     string `json:"date"`
+	Items    []Item `json:"items"`
+}
+
 type Item struct {
 	Name     string  `json:"name"`
 	Category string  `json:"category"`
@@ -77,14 +83,77 @@ const geminiReceiptPrompt = `
 必ず上記のJSON形式で返してください。
 `
 
+const geminiReceiptItemsPrompt = `
+あなたは画像解析の専門家です。次の画像に基づいて、レシートに記載されているアイテムを 1 行ずつ抽出し、JSON 形式で返してください。
+レシートに外税と記載のある場合、「アイテム名の頭に * マークが記されているもの」「アイテム名の頭に 外8 の記載があるもの」は税率を 0.08、それらが記されていない場合は 0.10 としてください。
+レシートに内税と記載のある場合、すべてのアイテムの税率を 0.00 としてください。
+アイテムの下に割引額が記載されている場合、その割引額を該当するアイテムの価格から差し引いてください。
+同じアイテムを複数個購入している場合は、1 行にまとめて合計金額を記載してください。
+
+返すべき情報の形式は以下の通りです:
+- 店舗名(merchant): レシートに記載されている店舗の名前
+- 日付(date): レシートの日付 (YYYY-MM-DD 形式)
+- アイテム(items): アイテムの一覧
+  - 名前(name): アイテム名
+  - カテゴリ(category): ぜいたくごはん, いつもごはん, 日用品, 住居費, 旅行, その他 のいずれか
+  - 金額(amount): 税抜きの金額 (割引後、内税の場合は記載の金額)
+  - 税率(tax): 0.08, 0.10, 0.00 のいずれか
+  - 日付(date): レシートの日付 (YYYY-MM-DD 形式)
+
+例:
+{
+	"merchant": "居酒屋ABC",
+	"date": "2024-06-15",
+	"items": [
+		{"name": "生ビール", "category": "ぜいたくごはん", "amount": 500, "tax": 0.10, "date": "2024-06-15"},
+		{"name": "枝豆", "category": "ぜいたくごはん", "amount": 380, "tax": 0.10, "date": "2024-06-15"}
+	]
+}
+
+必ず上記のJSON形式で返してください。
+`
+
 var ErrRateLimitExceeded = errors.New("rate limit exceeded")
 
 func (c *Client) GetReceiptData(imagePath string) (*ReceiptDataResponse, error) {
+	textResponse, err := c.generateFromImage(geminiReceiptPrompt, imagePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var result ReceiptDataResponse
+	if err := json.NewDecoder(strings.NewReader(textResponse)).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &result, nil
+}
+
+// GetReceiptItems はレシートを 1 行ずつのアイテムに分けて読み取る
+func (c *Client) GetReceiptItems(imagePath string) (*ReceiptItemsResponse, error) {
+	textResponse, err := c.generateFromImage(geminiReceiptItemsPrompt, imagePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var result ReceiptItemsResponse
+	if err := json.NewDecoder(strings.NewReader(textResponse)).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if len(result.Items) == 0 {
+		return nil, fmt.Errorf("no items in response")
+	}
+
+	return &result, nil
+}
+
+// generateFromImage はプロンプトと画像を Gemini に送り、返ってきたテキストを返す
+func (c *Client) generateFromImage(prompt string, imagePath string) (string, error) {
 	url := "https://generativelanguage.googleapis.com/v1beta/models/gemini-2.5-flash-lite:generateContent"
 
 	imageData, err := os.ReadFile(imagePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read image: %w", err)
+		return "", fmt.Errorf("failed to read image: %w", err)
 	}
 
 	base64Image := base64.StdEncoding.EncodeToString(imageData)
@@ -102,7 +171,7 @@ func (c *Client) GetReceiptData(imagePath string) (*ReceiptDataResponse, error)
 			{
 				"parts": []map[string]interface{}{
 					{
-						"text": geminiReceiptPrompt,
+						"text": prompt,
 					},
 					{
 						"inline_data": map[string]string{
@@ -118,13 +187,13 @@ func (c *Client) GetReceiptData(imagePath string) (*ReceiptDataResponse, error)
 	jsonData, err := json.Marshal(requestBody)
 	if err != nil {
 		fmt.Println("Failed to marshal request body:", err)
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+		return "", fmt.Errorf("failed to marshal request: %w", err)
 	}
 
 	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
 		fmt.Println("Failed to create request:", err)
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return "", fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
@@ -133,18 +202,18 @@ func (c *Client) GetReceiptData(imagePath string) (*ReceiptDataResponse, error)
 	resp, err := c.http.Do(req)
 	if err != nil {
 		fmt.Println("Failed to send request:", err)
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return "", fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode == 429 {
 		fmt.Println("Rate limit exceeded")
-		return nil, ErrRateLimitExceeded
+		return "", ErrRateLimitExceeded
 	}
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		fmt.Println("API request failed with status", resp.StatusCode, "body:", string(body))
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+		return "", fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
 	}
 
 	bodystr := new(bytes.Buffer)
@@ -202,11 +271,11 @@ func (c *Client) GetReceiptData(imagePath string) (*ReceiptDataResponse, error)
 
 	var apiResp apiResponse
 	if err := json.NewDecoder(bytes.NewReader(bodystr.Bytes())).Decode(&apiResp); err != nil {
-		return nil, fmt.Errorf("failed to decode API response: %w", err)
+		return "", fmt.Errorf("failed to decode API response: %w", err)
 	}
 
 	if len(apiResp.Candidates) == 0 || len(apiResp.Candidates[0].Content.Parts) == 0 {
-		return nil, fmt.Errorf("no content in API response")
+		return "", fmt.Errorf("no content in API response")
 	}
 
 	textResponse := apiResp.Candidates[0].Content.Parts[0].Text
@@ -220,10 +289,5 @@ func (c *Client) GetReceiptData(imagePath string) (*ReceiptDataResponse, error)
 
 	fmt.Println("Full response text:", textResponse)
 
-	var result ReceiptDataResponse
-	if err := json.NewDecoder(strings.NewReader(textResponse)).Decode(&result); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
-	}
-
-	return &result, nil
+	return textResponse, nil
 }
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+)
+
+// 呼びかけの名前
+const botName = "ぴょんちー"
+
+// コマンド名
+const (
+	CommandSplit          = "割り勘"
+	CommandExpense        = "家計簿"
+	CommandExpenseManual  = "家計簿つけて"
+	CommandSettle         = "精算"
+	CommandGroup          = "グループ"
+	CommandGroupDelete    = "グループ削除"
+	CommandRate           = "レート"
+	CommandGroupPaid      = "立て替え"
+	CommandOptionsRefresh = "選択肢更新"
+)
+
+// ParseCommand は "ぴょんちー 割り勘 12000 4" をコマンド名と引数に分ける
+// 全角スペース区切りや、名前とコマンドの間にスペースがなくてもいい
+func ParseCommand(content string) (name string, args []string, ok bool) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(content), botName)
+	if !ok {
+		return "", nil, false
+	}
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return "", nil, false
+	}
+	return fields[0], fields[1:], true
+}
+
+// isMentionArg はユーザーメンションの引数かどうか
+func isMentionArg(arg string) bool {
+	return strings.HasPrefix(arg, "<@") && strings.HasSuffix(arg, ">")
+}
+
+// parseYenArg は "1200" "1200円" "¥1,200" を金額として読む
+func parseYenArg(arg string) (int, bool) {
+	arg = strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(arg, "¥"), "￥"), "円")
+	n, err := strconv.Atoi(strings.ReplaceAll(arg, ",", ""))
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+	return n, true
+}
+
+// parsePeopleArg は "4人" を人数として読む
+func parsePeopleArg(arg string) (int, bool) {
+	v, ok := strings.CutSuffix(arg, "人")
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+	return n, true
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCommand(t *testing.T) {
+	name, args, ok := ParseCommand("ぴょんちー　割り勘 12000 4人")
+	if !ok || name != CommandSplit || !reflect.DeepEqual(args, []string{"12000", "4人"}) {
+		t.Fatalf("ParseCommand() = %q, %q, %v", name, args, ok)
+	}
+
+	if _, _, ok := ParseCommand("割り勘 12000"); ok {
+		t.Fatal("ParseCommand() without bot name should not match")
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	st := &SplitState{}
+	if err := st.applyArgs([]string{"12000円", "<@123>", "4"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if st.Total != 12000 || st.People != 4 {
+		t.Fatalf("applyArgs() = total %d, people %d", st.Total, st.People)
+	}
+
+	for _, args := range [][]string{{"1000", "1000000000"}, {"1000", "101人"}} {
+		if err := (&SplitState{}).applyArgs(args, nil); err == nil {
+			t.Errorf("applyArgs(%q) should fail", args)
+		}
+	}
+}
+
+func TestExpenseArgs(t *testing.T) {
+	st := &ExpenceState{}
+	if err := st.applyArgs([]string{"ランチ", "1200", "いつもごはん", "ぽよ財布"}); err != nil {
+		t.Fatal(err)
+	}
+	want := ExpenceState{Title: "ランチ", Amount: 1200, Category: "いつもごはん", People: 1, Wallet: "ぽよ財布"}
+	if !reflect.DeepEqual(*st, want) {
+		t.Fatalf("applyArgs() = %+v, want %+v", *st, want)
+	}
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+
+	"pyonchi/internal/convo"
+)
+
+func isCancelWord(content string) bool {
+	c := strings.TrimSpace(content)
+	return c == "やめる" || c == "キャンセル"
+}
+
+func isRestartWord(content string) bool {
+	return strings.TrimSpace(content) == "最初から"
+}
+
+// cancelButtonRow は st の会話をやめるボタンの行
+func cancelButtonRow(st convo.State) discordgo.ActionsRow {
+	return discordgo.ActionsRow{
+		Components: []discordgo.MessageComponent{
+			discordgo.Button{
+				Label:    "やめる",
+				Style:    discordgo.SecondaryButton,
+				CustomID: convo.CustomID(actionCancel, "", st),
+			},
+		},
+	}
+}
+
+// endConversations は key の進行中の会話をすべて終わらせる
+// 何か終わらせた場合は true を返す
+func endConversations(key string) bool {
+	ended := false
+	for _, c := range conversations() {
+		if c.Active(key) {
+			c.Cancel(key)
+			ended = true
+		}
+	}
+	return ended
+}
+
+// cancelByButton は やめるボタンで t の会話を終わらせる
+func cancelByButton(s *discordgo.Session, i *discordgo.InteractionCreate, t convo.Target) {
+	reply := interactionReply(s, i)
+	for _, c := range conversations() {
+		if c.Owns(t) {
+			c.Cancel(t.Key)
+			reply.Text("🛑 キャンセルしたよ")
+			return
+		}
+	}
+	reply.Private("⚠️ やめる会話がないよ")
+}
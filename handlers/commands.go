@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+
+	"pyonchi/internal/convo"
+	"pyonchi/internal/split"
+)
+
+// スラッシュコマンドの名前
+const (
+	commandKakeibo = "kakeibo"
+	commandWarikan = "warikan"
+	commandReceipt = "receipt"
+)
+
+// ApplicationCommands は起動時に登録するスラッシュコマンド
+func ApplicationCommands() []*discordgo.ApplicationCommand {
+	minAmount := 1.0
+	maxPeople := float64(split.MaxPeople)
+
+	return []*discordgo.ApplicationCommand{
+		{
+			Name:        commandKakeibo,
+			Description: "家計簿をつける",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "title",
+					Description: "タイトル",
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "amount",
+					Description: "金額 (ぜいたくごはんは一人あたり)",
+					MinValue:    &minAmount,
+				},
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "category",
+					Description:  "カテゴリ",
+					Autocomplete: true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "people",
+					Description: "何人分支払ったか (ぜいたくごはんのとき)",
+					MinValue:    &minAmount,
+				},
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "wallet",
+					Description:  "支払った財布",
+					Autocomplete: true,
+				},
+			},
+		},
+		{
+			Name:        commandWarikan,
+			Description: "割り勘する",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "amount",
+					Description: "合計金額",
+					MinValue:    &minAmount,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "people",
+					Description: "人数",
+					MinValue:    &minAmount,
+					MaxValue:    maxPeople,
+				},
+			},
+		},
+		{
+			Name:        commandReceipt,
+			Description: "レシートの画像から家計簿をつける",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionAttachment,
+					Name:        "image",
+					Description: "レシートの画像",
+					Required:    true,
+				},
+			},
+		},
+	}
+}
+
+// commandChoices は values のうち、入力中の typed を含むものを候補にする
+func commandChoices(values []string, typed string) []*discordgo.ApplicationCommandOptionChoice {
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, 0, len(values))
+	for _, v := range values {
+		if strings.Contains(v, typed) {
+			choices = append(choices, &discordgo.ApplicationCommandOptionChoice{Name: v, Value: v})
+		}
+	}
+	return choices
+}
+
+// --- スラッシュコマンドの入力候補をハンドリングする関数 ---
+// カテゴリと財布は Notion の選択肢が変わるので、登録時ではなく入力のたびに候補を返す
+func handleAutocomplete(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	var choices []*discordgo.ApplicationCommandOptionChoice
+	for _, o := range i.ApplicationCommandData().Options {
+		if !o.Focused {
+			continue
+		}
+		switch o.Name {
+		case "category":
+			choices = commandChoices(expenseCategories(), o.StringValue())
+		case "wallet":
+			choices = commandChoices(expenseWallets(), o.StringValue())
+		}
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{Choices: choices},
+	})
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// --- スラッシュコマンドをハンドリングする関数 ---
+func handleCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	data := i.ApplicationCommandData()
+	opts := map[string]*discordgo.ApplicationCommandInteractionDataOption{}
+	for _, o := range data.Options {
+		opts[o.Name] = o
+	}
+	key := convo.Key(i.ChannelID, interactionUserID(i))
+
+	switch data.Name {
+	case commandKakeibo:
+		endConversations(key)
+		st := &ExpenceState{}
+		if o, ok := opts["title"]; ok {
+			st.Title = o.StringValue()
+		}
+		// 候補から選ばずに打ち込まれた値は、あとで選び直してもらう
+		if o, ok := opts["category"]; ok && slices.Contains(expenseCategories(), o.StringValue()) {
+			st.setCategory(o.StringValue())
+		}
+		if o, ok := opts["amount"]; ok {
+			st.Amount = int(o.IntValue())
+		}
+		if o, ok := opts["people"]; ok {
+			if st.Category != "" && st.Category != categorySharedMeal {
+				interactionReply(s, i).Private("⚠️ 人数が書けるのはぜいたくごはんだけだよ")
+				return
+			}
+			st.People = int(o.IntValue())
+		}
+		if o, ok := opts["wallet"]; ok && slices.Contains(expenseWallets(), o.StringValue()) {
+			st.Wallet = o.StringValue()
+		}
+		expenseFlow.StartWith(key, st, interactionReply(s, i))
+
+	case commandWarikan:
+		endConversations(key)
+		st := &SplitState{}
+		if o, ok := opts["amount"]; ok {
+			st.Total = int(o.IntValue())
+		}
+		if o, ok := opts["people"]; ok {
+			if o.IntValue() > split.MaxPeople {
+				interactionReply(s, i).Private("⚠️ " + errTooManyPeople.Error())
+				return
+			}
+			st.People = int(o.IntValue())
+		}
+		splitFlow.StartWith(key, st, interactionReply(s, i))
+
+	case commandReceipt:
+		endConversations(key)
+		in := convo.Input{}
+		if o, ok := opts["image"]; ok && data.Resolved != nil {
+			if a, ok := data.Resolved.Attachments[o.StringValue()]; ok {
+				in.AttachmentURL = a.URL
+			}
+		}
+		// 解析に時間がかかるので先に応答しておく
+		reply := deferredInteractionReply(s, i)
+		receiptFlow.BeginWith(key, in, reply)
+	}
+}
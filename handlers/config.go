@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"pyonchi/internal/convo"
+)
+
+// 設定の保存先の名前空間
+const nsGroups = "groups"
+
+var (
+	configStore convo.Store = convo.NewMemoryStore()
+	configMu    sync.Mutex
+)
+
+// SetConfigStore は保存しておく設定 (いつものメンバーなど) の保存先を差し替える
+func SetConfigStore(st convo.Store) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	configStore = st
+}
+
+// loadConfig は ns/key の設定を v に読み込む。なければ false
+func loadConfig(ns, key string, v any) (bool, error) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	return readConfig(ns, key, v)
+}
+
+// updateConfig は ns/key の設定を v に読み込んで fn で書き換え、保存する
+// 読んでから保存するまでロックしたままなので、同時に書き換えても消えない。fn がエラーなら保存しない
+func updateConfig(ns, key string, v any, fn func() error) error {
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	if _, err := readConfig(ns, key, v); err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		return err
+	}
+	return writeConfig(ns, key, v)
+}
+
+func readConfig(ns, key string, v any) (bool, error) {
+	b, ok, err := configStore.Get(ns, key)
+	if err != nil {
+		return false, fmt.Errorf("failed to load config: %w", err)
+	}
+	if !ok {
+		return false, nil
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return false, fmt.Errorf("failed to decode config: %w", err)
+	}
+	return true, nil
+}
+
+func writeConfig(ns, key string, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	if err := configStore.Put(ns, key, b); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	return nil
+}
@@ -2,42 +2,37 @@ package handlers
 
 import (
 	"errors"
-	"fmt"
-	"image"
-	"image/jpeg"
-	"image/png"
-	"io"
 	"log"
-	"net/http"
-	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
 
-	"pyonchi/gemini"
+	"pyonchi/internal/convo"
+	"pyonchi/internal/currency"
 	"pyonchi/notion"
 )
 
 type ExpenceState struct {
-	Step     int
-	Title    string
-	Category string
-	Amount   int
-	Tax      float32
-	People   int
-	Wallet   string
+	convo.Cursor
+	Title    string  `json:"title"`
+	Category string  `json:"category"`
+	Amount   int     `json:"amount"`
+	Tax      float32 `json:"tax"`
+	People   int     `json:"people"`
+	Wallet   string  `json:"wallet"`
+	// Original は外貨で払ったときの一人あたりの現地の金額。Amount は円に換算した額
+	Original currency.Money `json:"original"`
+	// Members はメンションされた一緒に払ってもらった人のユーザー ID。ぜいたくごはんなら台帳につける
+	Members []string `json:"members"`
+
+	// Editing は確認画面から修正に来ているとき true
+	Editing bool `json:"editing"`
+	// Edit は確認画面で選ばれた修正先のステップ
+	Edit string `json:"edit"`
 }
-type ReceiptData struct {
-	Merchant string
-	Category string
-	Amount   int
-	Date     string
-}
-
-var expenseConversationState = map[string]*ExpenceState{}
-var expenseReceiptConversationState = map[string]*ReceiptData{}
 
 var client *notion.Client
 
@@ -45,505 +40,410 @@ func SetNotionClient(cli *notion.Client) {
 	client = cli
 }
 
-// 会話中かどうかを判定
-func IsInExpenseConversation(key string) bool {
-	_, exists := expenseConversationState[key]
-	return exists
-}
-
-// レシート画像から家計簿記録を行う会話中かどうかを判定
-func IsInExpenseReceiptConversation(key string) bool {
-	_, exists := expenseReceiptConversationState[key]
-	return exists
-}
-
+// 家計簿記録のステップ
 const (
-	StepInputTitle                 = 100
-	StepGetTitleAndRequestCategory = 101
-	StepInputCategory              = 200
-	StepGetCategory                = 201
-	StepInputAmountPerPerson       = 300
-	StepGetAmountPerPerson         = 301
-	StepInputPeople                = 400
-	StepGetPeople                  = 401
-	StepSelectWallet               = 500
-	StepGetReceiptData             = 600
+	stepExpenseTitle    = "title"
+	stepExpenseCategory = "category"
+	stepExpenseAmount   = "amount"
+	stepExpensePeople   = "people"
+	stepExpenseWallet   = "wallet"
+	stepExpenseConfirm  = "confirm"
 )
 
-func ExpenseManualHandleOngoing(s *discordgo.Session, m *discordgo.MessageCreate) {
-	key := m.ChannelID + "|" + m.Author.ID
-	state, ok := expenseConversationState[key]
-	if !ok {
-		state = &ExpenceState{
-			Step: StepInputTitle,
-		}
-		expenseConversationState[key] = state
-	}
-
-	switch state.Step {
-	case StepInputTitle:
-		RequestInputTitle(s, m)
-		state.Step = StepGetTitleAndRequestCategory
-		return
-	case StepGetTitleAndRequestCategory:
-		title := GetInputTitle(m)
-		if title == "" {
-			s.ChannelMessageSend(m.ChannelID, "⚠️ タイトル教えてよ")
+// 確認画面の確定ボタンの値 (修正ボタンは修正先のステップ名)
+const confirmActionOK = "ok"
+
+var expenseFlow = &convo.Flow[*ExpenceState]{
+	New:   func() *ExpenceState { return &ExpenceState{} },
+	First: stepExpenseTitle,
+	Steps: map[string]*convo.Step[*ExpenceState]{
+		stepExpenseTitle: {
+			Prompt: expenseTitlePrompt,
+			Parse: func(st *ExpenceState, in convo.Input) error {
+				if in.Fields != nil {
+					return applyExpenseForm(st, in.Fields)
+				}
+				title := strings.TrimSpace(in.Text)
+				if title == "" {
+					return errors.New("タイトル教えてよ")
+				}
+				st.Title = title
+				return nil
+			},
+			Next: expenseNext(stepExpenseCategory),
+			Skip: func(st *ExpenceState) bool { return !st.Editing && st.Title != "" },
+		},
+		stepExpenseCategory: {
+			Prompt: func(st *ExpenceState) *discordgo.MessageSend {
+				return selectPrompt(st, "どんな出費？", actionExpenseCategory, "支出カテゴリを選んでよね", expenseCategories())
+			},
+			Parse: func(st *ExpenceState, in convo.Input) error {
+				category, err := parseOption(in.Text, expenseCategories(), "カテゴリ")
+				if err != nil {
+					return err
+				}
+				// 確認画面からぜいたくごはんに変えたら、人数を聞き直す
+				if st.Editing && category == categorySharedMeal && st.Category != category {
+					st.People = 0
+				}
+				st.setCategory(category)
+				return nil
+			},
+			Next: func(st *ExpenceState) string {
+				if st.Editing && st.People == 0 {
+					return stepExpensePeople
+				}
+				return expenseNext(stepExpenseAmount)(st)
+			},
+			Skip: func(st *ExpenceState) bool { return !st.Editing && st.Category != "" },
+		},
+		stepExpenseAmount: {
+			Prompt: func(st *ExpenceState) *discordgo.MessageSend {
+				if st.Category == categorySharedMeal {
+					return prompt(st, "一人あたりの金額はいくら？ (外貨なら `12.5USD` みたいに書いてね)")
+				}
+				return prompt(st, "金額はいくら？ (外貨なら `12.5USD` みたいに書いてね)")
+			},
+			Parse: func(st *ExpenceState, in convo.Input) error {
+				return st.setAmount(in.Text)
+			},
+			Next: func(st *ExpenceState) string {
+				// 確認画面から金額だけ直しに来たなら、人数はもう聞かない
+				if st.Category == categorySharedMeal && (!st.Editing || st.People == 0) {
+					return stepExpensePeople
+				}
+				return expenseNext(stepExpenseWallet)(st)
+			},
+			Skip: func(st *ExpenceState) bool { return !st.Editing && st.Amount > 0 },
+		},
+		stepExpensePeople: {
+			Prompt: func(st *ExpenceState) *discordgo.MessageSend {
+				return prompt(st, "何人分支払ったの？")
+			},
+			Parse: func(st *ExpenceState, in convo.Input) error {
+				people, err := strconv.Atoi(strings.TrimSpace(in.Text))
+				if err != nil || people <= 0 {
+					return errors.New("人数が変じゃない？")
+				}
+				st.People = people
+				return nil
+			},
+			Next: expenseNext(stepExpenseWallet),
+			Skip: func(st *ExpenceState) bool {
+				return st.Category != categorySharedMeal || (!st.Editing && st.People > 0)
+			},
+		},
+		stepExpenseWallet: {
+			Prompt: func(st *ExpenceState) *discordgo.MessageSend {
+				return selectPrompt(st, "どの財布から払ったの？", actionExpenseWallet, "支払い財布を選んでよね", expenseWallets())
+			},
+			Parse: func(st *ExpenceState, in convo.Input) error {
+				wallet, err := parseOption(in.Text, expenseWallets(), "財布")
+				if err != nil {
+					return err
+				}
+				st.Wallet = wallet
+				return nil
+			},
+			Next: convo.Then[*ExpenceState](stepExpenseConfirm),
+			Skip: func(st *ExpenceState) bool { return !st.Editing && st.Wallet != "" },
+		},
+		// --- Notion に書く前に内容を確認してもらう ---
+		stepExpenseConfirm: {
+			Prompt: expenseConfirmPrompt,
+			Parse: func(st *ExpenceState, in convo.Input) error {
+				switch strings.TrimSpace(in.Text) {
+				case confirmActionOK, "確定":
+					st.Edit = ""
+				case stepExpenseTitle, "タイトル修正":
+					st.Edit = stepExpenseTitle
+				case stepExpenseAmount, "金額修正":
+					st.Edit = stepExpenseAmount
+				case stepExpenseCategory, "カテゴリ修正":
+					st.Edit = stepExpenseCategory
+				default:
+					return errors.New("ボタンで選んでよね")
+				}
+				st.Editing = st.Edit != ""
+				return nil
+			},
+			Next: func(st *ExpenceState) string {
+				return st.Edit
+			},
+		},
+	},
+	Done: func(key string, st *ExpenceState, reply convo.Reply) {
+		// Notion に書き込み
+		err := client.CreateExpenseRecord(st.Title, st.Category, st.Amount, st.People, st.Wallet, time.Now(), st.Original.Currency, st.Original.Amount)
+		if err != nil {
+			reply.Text("⚠️ Notion に記録できなかった")
 			return
 		}
-		state.Title = title
 
-		RequestInputCategory(s, m)
-		state.Step = StepInputAmountPerPerson
-		return
-	case StepInputAmountPerPerson:
-		amt, err := strconv.Atoi(m.Content)
-		if err != nil || amt <= 0 {
-			s.ChannelMessageSend(m.ChannelID, "⚠️ 金額は整数にしてよね")
-			return
-		}
-		state.Amount = amt
-
-		if state.Category == "ぜいたくごはん" {
-			s.ChannelMessageSend(m.ChannelID, "何人分支払ったの？")
-			state.Step = StepGetPeople
-		} else {
-			state.People = 1
-			RequestInputWallet(s, m)
-		}
-		return
-	case StepGetPeople:
-		people, err := GetInputPeople(m)
-		if err != nil || people <= 0 {
-			s.ChannelMessageSend(m.ChannelID, "⚠️ 人数が変じゃない？")
-			return
-		}
-		state.People = people
-		RequestInputWallet(s, m)
-		return
-	default:
-		s.ChannelMessageSend(m.ChannelID, "⚠️ なんか変な状態になっちゃった")
-		delete(expenseConversationState, key)
-		return
-	}
+		// 結果を Discord に送信
+		reply.Text("🍽 家計簿つけたよ\n" +
+			"タイトル: " + st.Title + "\n" +
+			"一人あたり: " + strconv.Itoa(st.Amount) + "円" + originalNote(st.Original) + "\n" +
+			"人数: " + strconv.Itoa(st.People) + "人\n" +
+			"合計: " + strconv.Itoa(st.Amount*st.People) + "円\n" +
+			"財布: " + st.Wallet + "\n" +
+			expenseLedgerText(key, st) + "\n" +
+			getBudgetText(reply, st.Category))
+	},
 }
 
-// レシート画像から家計簿記録を行うハンドラ
-func ExpenseReceiptHandleOngoing(s *discordgo.Session, m *discordgo.MessageCreate, geminiClient *gemini.Client) {
-	key := m.ChannelID + "|" + m.Author.ID
-	_, ok := expenseReceiptConversationState[key]
-	if !ok {
-		expenseReceiptConversationState[key] = &ReceiptData{
-			Merchant: "",
-			Category: "",
-			Amount:   0,
-			Date:     "",
-		}
+// expenseLedgerText はみんなの分を払ったぜいたくごはんを台帳につける
+// 誰の分かはメンションでしかわからないので、メンションされた人の一人あたりの額だけつける
+func expenseLedgerText(key string, st *ExpenceState) string {
+	if st.Category != categorySharedMeal || st.People <= 1 || len(st.Members) == 0 {
+		return ""
 	}
+	var parties []string
+	var amounts []int
+	for _, id := range st.Members {
+		parties = append(parties, mention(id))
+		amounts = append(amounts, st.Amount)
+	}
+	return recordSplit(key, parties, amounts, st.Title+" "+strconv.Itoa(st.Amount*st.People)+"円")
+}
 
-	// 受け取ったレシート画像を処理してデータを取得
-	// 画像添付の最初のものを使う
-	imageURL := m.Attachments[0].URL
+// expenseTitlePrompt はタイトルを聞く。フォームでまとめて入力するボタンも出す
+func expenseTitlePrompt(st *ExpenceState) *discordgo.MessageSend {
+	msg := prompt(st, "タイトル教えて")
+	msg.Components = append([]discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{Label: "フォームでまとめて入力", Style: discordgo.PrimaryButton, CustomID: convo.CustomID(actionExpenseForm, "", st)},
+			},
+		},
+	}, msg.Components...)
+	return msg
+}
 
-	// 画像を一時ファイルにダウンロード
-	imagePath, err := downloadImageToTempFile(imageURL)
-	if err != nil {
-		s.ChannelMessageSend(m.ChannelID, "⚠️ 画像のダウンロードに失敗したよ")
-		delete(expenseReceiptConversationState, key)
-		return
+// applyExpenseForm はフォームで入力されたタイトル・金額・人数を検証して反映する
+func applyExpenseForm(st *ExpenceState, fields map[string]string) error {
+	title := strings.TrimSpace(fields["title"])
+	if title == "" {
+		return errors.New("タイトル教えてよ")
 	}
-	defer os.Remove(imagePath)
-
-	// 画像が横長の場合は縦長に回転させる
-	err = rotateImageIfLandscape(imagePath)
-	if err != nil {
-		s.ChannelMessageSend(m.ChannelID, "⚠️ 画像の回転に失敗したよ")
-		delete(expenseReceiptConversationState, key)
-		return
+	people, err := strconv.Atoi(strings.TrimSpace(fields["people"]))
+	if err != nil || people <= 0 {
+		return errors.New("人数が変じゃない？")
+	}
+	if err := st.setAmount(fields["amount"]); err != nil {
+		return err
 	}
 
-	// Gemini API を使ってレシートデータを取得
-	receiptData, err := geminiClient.GetReceiptData(imagePath)
-	if errors.Is(err, gemini.ErrRateLimitExceeded) {
-		s.ChannelMessageSend(m.ChannelID, "⚠️ AI の利用制限超えちゃった")
-		delete(expenseReceiptConversationState, key)
-		return
+	st.Title = title
+	st.People = people
+	return nil
+}
+
+// setAmount は金額を読む。外貨なら登録されているレートで円にする
+func (st *ExpenceState) setAmount(text string) error {
+	m, ok := currency.Parse(text)
+	if !ok {
+		return errors.New("金額は整数にしてよね")
 	}
+	yen, err := toJPY(m)
 	if err != nil {
-		s.ChannelMessageSend(m.ChannelID, "⚠️ レシートの解析に失敗したよ")
-		delete(expenseReceiptConversationState, key)
-		return
+		return err
 	}
-	defer os.Remove(imagePath)
-
-	// 解析結果をもとに map に保存
-	expenseReceiptConversationState[key] = &ReceiptData{
-		Merchant: receiptData.Merchant,
-		Category: receiptData.Category,
-		Amount:   receiptData.Amount,
-		Date:     receiptData.Date,
+	st.Amount = yen
+	st.Original = currency.Money{}
+	if !m.IsJPY() {
+		st.Original = m
 	}
-
-	RequestInputWalletForReceipt(s, m)
+	return nil
 }
 
-func RequestInputTitle(s *discordgo.Session, m *discordgo.MessageCreate) {
-	s.ChannelMessageSend(m.ChannelID, "タイトル教えて")
+// setCategory はカテゴリを決める
+// ぜいたくごはん以外は一人分として記録する
+func (st *ExpenceState) setCategory(category string) {
+	st.Category = category
+	if category != categorySharedMeal {
+		st.People = 1
+	}
 }
 
-func RequestInputCategory(s *discordgo.Session, m *discordgo.MessageCreate) {
-	s.ChannelMessageSendComplex(m.ChannelID, &discordgo.MessageSend{
-		Content: "どんな出費？",
-		Components: []discordgo.MessageComponent{
-			discordgo.ActionsRow{
-				Components: []discordgo.MessageComponent{
-					discordgo.SelectMenu{
-						MenuType: discordgo.StringSelectMenu,
-						CustomID: "expense_category_select",
-						Options: []discordgo.SelectMenuOption{
-							{
-								Label: "いつもごはん",
-								Value: "いつもごはん",
-							},
-							{
-								Label: "ぜいたくごはん",
-								Value: "ぜいたくごはん",
-							},
-							{
-								Label: "日用品",
-								Value: "日用品",
-							},
-							{
-								Label: "住居費",
-								Value: "住居費",
-							},
-							{
-								Label: "旅行",
-								Value: "旅行",
-							},
-							{
-								Label: "その他",
-								Value: "その他",
-							},
-						},
-						Placeholder: "支出カテゴリを選んでよね",
-					},
-				},
-			},
-		},
-	})
+// expenseNext は next に進む Next を返す
+// 確認画面から修正に来ている場合は確認画面に戻る
+func expenseNext(next string) func(st *ExpenceState) string {
+	return func(st *ExpenceState) string {
+		if st.Editing {
+			return stepExpenseConfirm
+		}
+		return next
+	}
 }
 
-func RequestInputWallet(s *discordgo.Session, m *discordgo.MessageCreate) {
-	s.ChannelMessageSendComplex(m.ChannelID, &discordgo.MessageSend{
-		Content: "どの財布から払ったの？",
-		Components: []discordgo.MessageComponent{
-			discordgo.ActionsRow{
-				Components: []discordgo.MessageComponent{
-					discordgo.SelectMenu{
-						MenuType: discordgo.StringSelectMenu,
-						CustomID: "expense_wallet_select",
-						Options: []discordgo.SelectMenuOption{
-							{
-								Label: "おひ財布",
-								Value: "おひ財布",
-							},
-							{
-								Label: "ぽよ財布",
-								Value: "ぽよ財布",
-							},
-							{
-								Label: "B/43",
-								Value: "B/43",
-							},
-						},
-						Placeholder: "支払い財布を選んでよね",
-					},
+// expenseConfirmPrompt は記録する内容と修正ボタンを出す
+func expenseConfirmPrompt(st *ExpenceState) *discordgo.MessageSend {
+	button := func(label, value string, style discordgo.ButtonStyle) discordgo.Button {
+		return discordgo.Button{Label: label, Style: style, CustomID: convo.CustomID(actionExpenseConfirm, value, st)}
+	}
+
+	return &discordgo.MessageSend{
+		Content: "この内容で記録していい？",
+		Embeds: []*discordgo.MessageEmbed{
+			{
+				Title: "🍽 " + st.Title,
+				Fields: []*discordgo.MessageEmbedField{
+					{Name: "カテゴリ", Value: st.Category, Inline: true},
+					{Name: "一人あたり", Value: strconv.Itoa(st.Amount) + "円" + originalNote(st.Original), Inline: true},
+					{Name: "人数", Value: strconv.Itoa(st.People) + "人", Inline: true},
+					{Name: "合計", Value: strconv.Itoa(st.Amount*st.People) + "円", Inline: true},
+					{Name: "財布", Value: st.Wallet, Inline: true},
 				},
 			},
 		},
-	})
-}
-
-func RequestInputWalletForReceipt(s *discordgo.Session, m *discordgo.MessageCreate) {
-	s.ChannelMessageSendComplex(m.ChannelID, &discordgo.MessageSend{
-		Content: "どの財布から払ったの？",
 		Components: []discordgo.MessageComponent{
 			discordgo.ActionsRow{
 				Components: []discordgo.MessageComponent{
-					discordgo.SelectMenu{
-						MenuType: discordgo.StringSelectMenu,
-						CustomID: "expense_receipt_wallet_select",
-						Options: []discordgo.SelectMenuOption{
-							{
-								Label: "おひ財布",
-								Value: "おひ財布",
-							},
-							{
-								Label: "ぽよ財布",
-								Value: "ぽよ財布",
-							},
-							{
-								Label: "B/43",
-								Value: "B/43",
-							},
-						},
-						Placeholder: "支払い財布を選んでよね",
-					},
+					button("確定", confirmActionOK, discordgo.SuccessButton),
+					button("タイトル修正", stepExpenseTitle, discordgo.SecondaryButton),
+					button("金額修正", stepExpenseAmount, discordgo.SecondaryButton),
+					button("カテゴリ修正", stepExpenseCategory, discordgo.SecondaryButton),
 				},
 			},
+			cancelButtonRow(st),
 		},
-	})
+	}
 }
 
-func GetInputTitle(m *discordgo.MessageCreate) string {
-	title := m.Content
-	return title
+// 会話中かどうかを判定
+func IsInExpenseConversation(key string) bool {
+	return expenseFlow.Active(key)
 }
 
-func GetInputCategory(m *discordgo.MessageCreate) string {
-	category := m.Content
-	return category
-}
+// 家計簿記録を始める。会話中ならメッセージを続きとして処理する
+// 引数があれば、会話中でもその内容で始め直して、足りないところだけ聞く
+func ExpenseManualHandleOngoing(s *discordgo.Session, m *discordgo.MessageCreate) {
+	key := convo.Key(m.ChannelID, m.Author.ID)
+	reply := messageReply(s, m.ChannelID)
 
-func GetInputPeople(m *discordgo.MessageCreate) (int, error) {
-	ppl, err := strconv.Atoi(m.Content)
-	if err != nil {
-		return 0, err
+	_, args, _ := ParseCommand(m.Content)
+	if expenseFlow.Active(key) && len(args) == 0 {
+		expenseFlow.Handle(key, messageInput(m), reply)
+		return
 	}
-	return ppl, nil
-}
-
-// --- 財布を選択するプルダウンのインタラクションをハンドリングする関数 ---
-func WalletInteractionHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	if i.MessageComponentData().CustomID == "expense_wallet_select" {
-		// ここで選択された財布の値を取得
-		wallet := i.MessageComponentData().Values[0]
-
-		fmt.Println(i.ChannelID, i.Member.User.ID)
-		fmt.Println(expenseConversationState)
-		state := expenseConversationState[i.ChannelID+"|"+i.Member.User.ID]
-
-		total := state.Amount * state.People
-		now := time.Now()
-
-		// Notion に書き込み
-		err := client.CreateExpenseRecord(state.Title, state.Category, state.Amount, state.People, wallet, now)
-
-		if err != nil {
-			s.ChannelMessageSend(i.ChannelID, "⚠️ Notion に記録できなかった")
-			delete(expenseConversationState, i.ChannelID+"|"+i.Member.User.ID)
-			return
-		}
-
-		budgets := getBudgetText(s, i, state.Category)
-
-		// 結果を Discord に送信
-		msg := "🍽 家計簿つけたよ\n" +
-			"タイトル: " + state.Title + "\n" +
-			"一人あたり: " + strconv.Itoa(state.Amount) + "円\n" +
-			"人数: " + strconv.Itoa(state.People) + "人\n" +
-			"合計: " + strconv.Itoa(total) + "円\n" +
-			"財布: " + wallet + "\n\n" +
-			budgets
-
-		resp := &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Flags:   discordgo.MessageFlagsHasThread,
-				Content: msg,
-			},
-		}
-		if err := s.InteractionRespond(i.Interaction, resp); err != nil {
-			log.Fatalln(err)
-		}
 
-		// 🔚 会話終了
-		delete(expenseConversationState, i.ChannelID+"|"+i.Member.User.ID)
+	st := &ExpenceState{}
+	if err := st.applyArgs(args); err != nil {
+		reply.Text("⚠️ " + err.Error())
+		return
+	}
+	// メンションされた人は一緒にぜいたくごはんを食べた人。人数がなければ自分と合わせた人数にする
+	st.Members = splitMembers(m)[1:]
+	if len(st.Members) > 0 && st.People <= 1 && (st.Category == "" || st.Category == categorySharedMeal) {
+		st.People = len(st.Members) + 1
 	}
+	expenseFlow.StartWith(key, st, reply)
 }
 
-// --- 財布を選択するプルダウンのインタラクションをハンドリングする関数 ---
-func ReceiptWalletInteractionHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	fmt.Println("ReceiptWalletInteractionHandler called")
-	if i.MessageComponentData().CustomID == "expense_receipt_wallet_select" {
-		fmt.Println("Processing receipt wallet selection")
-		// ここで選択された財布の値を取得
-		wallet := i.MessageComponentData().Values[0]
-
-		state := expenseReceiptConversationState[i.ChannelID+"|"+i.Member.User.ID]
-		fmt.Println(i.ChannelID, i.Member.User.ID)
-		fmt.Println(expenseReceiptConversationState)
-
-		var msgs []string
-
-		dateTime, err := time.Parse("2006-01-02", state.Date)
-		if err != nil {
-			s.ChannelMessageSend(i.ChannelID, "⚠️ 日付の解析に失敗したよ")
-			delete(expenseReceiptConversationState, i.ChannelID+"|"+i.Member.User.ID)
-			return
-		}
-
-		// Notion に書き込み
-		err = client.CreateExpenseRecord(state.Merchant, state.Category, state.Amount, 1, wallet, dateTime)
-
-		if err != nil {
-			s.ChannelMessageSend(i.ChannelID, "⚠️ Notion に記録できなかった")
-			delete(expenseReceiptConversationState, i.ChannelID+"|"+i.Member.User.ID)
-			return
-		}
-
-		budgets := getBudgetText(s, i, state.Category)
-
-		msgs = append(msgs, "🍽 家計簿つけたよ\n"+
-			"タイトル: "+state.Merchant+"\n"+
-			"一人あたり: "+strconv.Itoa(state.Amount)+"円\n"+
-			"人数: "+strconv.Itoa(1)+"人\n"+
-			"合計: "+strconv.Itoa(state.Amount*1)+"円\n"+
-			"財布: "+wallet+"\n\n"+
-			budgets)
-
-		// 結果を Discord に送信
-		msg := strings.Join(msgs, "\n")
-
-		resp := &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Flags:   discordgo.MessageFlagsHasThread,
-				Content: msg,
-			},
-		}
-		if err := s.InteractionRespond(i.Interaction, resp); err != nil {
-			log.Fatalln(err)
+// applyArgs は "ぴょんちー 家計簿 ランチ 1200 いつもごはん ぽよ財布" の引数を埋める
+// カテゴリと財布はリストの名前、数字は金額、"2人" は人数で、残りはタイトルにする
+func (st *ExpenceState) applyArgs(args []string) error {
+	var title []string
+	people := 0
+	for _, arg := range args {
+		switch {
+		case isMentionArg(arg):
+			continue
+		case slices.Contains(expenseCategories(), arg):
+			st.Category = arg
+		case slices.Contains(expenseWallets(), arg):
+			st.Wallet = arg
+		default:
+			if n, ok := parsePeopleArg(arg); ok {
+				people = n
+				continue
+			}
+			if _, ok := currency.Parse(arg); ok && st.Amount == 0 {
+				if err := st.setAmount(arg); err != nil {
+					return err
+				}
+				continue
+			}
+			title = append(title, arg)
 		}
-
-		s.ChannelMessageSend(i.ChannelID, "間違ってるときは https://www.notion.so/2b8531cb924680c39071c2090c53ff96?v=2b8531cb924680f0b01c000c5bf9d7ef から修正して")
-
-		// 🔚 会話終了
-		delete(expenseReceiptConversationState, i.ChannelID+"|"+i.Member.User.ID)
 	}
-}
-
-func CategoryInteractionHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	if i.MessageComponentData().CustomID == "expense_category_select" {
-		// ここで選択されたカテゴリの値を取得
-		category := i.MessageComponentData().Values[0]
-
-		fmt.Println(i.ChannelID, i.Member.User.ID)
-		fmt.Println(expenseConversationState)
-		state := expenseConversationState[i.ChannelID+"|"+i.Member.User.ID]
-
-		// カテゴリ保存して次のステップへ
-		state.Category = category
-		state.Step = StepInputAmountPerPerson
 
-		var msg string
-		if category == "ぜいたくごはん" {
-			msg = "一人あたりの金額はいくら？"
-		} else {
-			msg = "金額はいくら？"
-		}
-		resp := &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Content: msg,
-			},
-		}
-		if err := s.InteractionRespond(i.Interaction, resp); err != nil {
-			log.Fatalln(err)
+	st.Title = strings.Join(title, " ")
+	if st.Category != "" {
+		st.setCategory(st.Category)
+	}
+	if people > 0 {
+		if st.Category != "" && st.Category != categorySharedMeal {
+			return errors.New("人数が書けるのはぜいたくごはんだけだよ")
 		}
+		st.People = people
 	}
+	return nil
 }
 
-func getBudgetText(s *discordgo.Session, i *discordgo.InteractionCreate, category string) string {
-	var monthTotal int
-	var err error
-
-	// 今月の外食合計を取得
-	monthTotal, err = client.GetMonthlyExpenseTotal(category)
-	if err != nil {
-		s.ChannelMessageSend(i.ChannelID, "⚠️ 今月の"+category+"代が取得できなかったんだけど")
-		delete(expenseConversationState, i.ChannelID+"|"+i.Member.User.ID)
-		return ""
+// --- 家計簿記録のボタンやプルダウンをハンドリングする関数 ---
+func handleExpenseComponent(s *discordgo.Session, i *discordgo.InteractionCreate, t convo.Target) {
+	reply := interactionReply(s, i)
+
+	switch t.Action {
+	case actionExpenseCategory:
+		expenseFlow.Answer(t, stepExpenseCategory, componentInput(i, t), reply)
+	case actionExpenseWallet:
+		expenseFlow.Answer(t, stepExpenseWallet, componentInput(i, t), reply)
+	case actionExpenseConfirm:
+		expenseFlow.Answer(t, stepExpenseConfirm, componentInput(i, t), reply)
+	case actionExpenseForm:
+		openExpenseFormModal(s, i, t)
+	case actionExpenseFormModal:
+		expenseFlow.Answer(t, stepExpenseTitle, modalInput(i), reply)
 	}
-
-	return "📊 今月の" + category + "合計は **" + strconv.Itoa(monthTotal) + "円** みたい"
 }
 
-func downloadImageToTempFile(url string) (string, error) {
-	resp, err := http.Get(url)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	tmpFile, err := os.CreateTemp("", "receipt_*.jpg")
-	if err != nil {
-		return "", err
+// openExpenseFormModal はタイトル・金額・人数をまとめて入力するモーダルを開く
+func openExpenseFormModal(s *discordgo.Session, i *discordgo.InteractionCreate, t convo.Target) {
+	st, ok := expenseFlow.Lookup(t, stepExpenseTitle)
+	if !ok {
+		interactionReply(s, i).Private("⚠️ その操作はもう受け付けてないよ")
+		return
 	}
-	defer tmpFile.Close()
 
-	_, err = io.Copy(tmpFile, resp.Body)
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseModal,
+		Data: &discordgo.InteractionResponseData{
+			CustomID: convo.CustomID(actionExpenseFormModal, "", st),
+			Title:    "家計簿をつける",
+			Components: []discordgo.MessageComponent{
+				textInputRow("title", "タイトル", "ランチ", ""),
+				textInputRow("amount", "金額 (ぜいたくごはんは一人あたり)", "1200", ""),
+				textInputRow("people", "人数 (ぜいたくごはんのとき)", "", "1"),
+			},
+		},
+	})
 	if err != nil {
-		return "", err
+		log.Println(err)
 	}
-
-	return tmpFile.Name(), nil
 }
 
-// rotateImageIfLandscape は画像が横長の場合に90度回転させる
-func rotateImageIfLandscape(imagePath string) error {
-	// 画像ファイルを開く
-	file, err := os.Open(imagePath)
-	if err != nil {
-		return fmt.Errorf("failed to open image: %w", err)
-	}
-	defer file.Close()
-
-	// 画像をデコード
-	img, format, err := image.Decode(file)
-	if err != nil {
-		return fmt.Errorf("failed to decode image: %w", err)
-	}
-
-	bounds := img.Bounds()
-	width := bounds.Dx()
-	height := bounds.Dy()
-
-	// 横長でない場合は何もしない
-	if width <= height {
-		return nil
-	}
-
-	// 90度回転（時計回りに回転）
-	rotated := image.NewRGBA(image.Rect(0, 0, height, width))
-	for x := 0; x < width; x++ {
-		for y := 0; y < height; y++ {
-			rotated.Set(height-1-y, x, img.At(x, y))
+// parseOption は選択肢の中から入力に一致するものを返す
+// プルダウンのほか、チャットで直接打たれた場合も受け付ける
+func parseOption(input string, options []string, name string) (string, error) {
+	input = strings.TrimSpace(input)
+	for _, o := range options {
+		if o == input {
+			return o, nil
 		}
 	}
+	return "", errors.New(name + "はリストから選んでよね")
+}
 
-	// 回転した画像を元のファイルに上書き保存
-	outFile, err := os.Create(imagePath)
-	if err != nil {
-		return fmt.Errorf("failed to create output file: %w", err)
-	}
-	defer outFile.Close()
-
-	// フォーマットに応じてエンコード
-	switch format {
-	case "jpeg", "jpg":
-		err = jpeg.Encode(outFile, rotated, &jpeg.Options{Quality: 95})
-	case "png":
-		err = png.Encode(outFile, rotated)
-	default:
-		// デフォルトはJPEGとして保存
-		err = jpeg.Encode(outFile, rotated, &jpeg.Options{Quality: 95})
-	}
-
+func getBudgetText(reply convo.Reply, category string) string {
+	// 今月のカテゴリ合計を取得
+	monthTotal, err := client.GetMonthlyExpenseTotal(category)
 	if err != nil {
-		return fmt.Errorf("failed to encode image: %w", err)
+		reply.Text("⚠️ 今月の" + category + "代が取得できなかったんだけど")
+		return ""
 	}
 
-	return nil
+	return "📊 今月の" + category + "合計は **" + strconv.Itoa(monthTotal) + "円** みたい"
 }
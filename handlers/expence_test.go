@@ -2,6 +2,10 @@ package handlers
 
 import (
 	"testing"
+
+	"github.com/bwmarrin/discordgo"
+
+	"pyonchi/internal/convo"
 )
 
 func TestExpenseManualHandleOngoing(t *testing.T) {
@@ -9,3 +13,43 @@ func TestExpenseManualHandleOngoing(t *testing.T) {
 		// 何かしらのテストを書く
 	})
 }
+
+func TestExpenseEditCategoryAsksPeople(t *testing.T) {
+	key := convo.Key("channel", "user")
+	st := expenseFlow.Begin(key)
+	st.Title, st.Category, st.Amount, st.People, st.Wallet = "ランチ", "いつもごはん", 1200, 1, "おひ財布"
+	st.Editing = true
+	st.SetStep(stepExpenseCategory)
+	expenseFlow.Sessions.Put(key, st)
+	defer expenseFlow.Cancel(key)
+
+	expenseFlow.Handle(key, convo.Input{Text: "ぜいたくごはん"}, func(*discordgo.MessageSend) {})
+
+	got, ok := expenseFlow.Sessions.Get(key)
+	if !ok {
+		t.Fatal("conversation should continue")
+	}
+	if got.CurrentStep() != stepExpensePeople {
+		t.Fatalf("step = %q, want %q", got.CurrentStep(), stepExpensePeople)
+	}
+}
+
+func TestExpenseEditAmountReturnsToConfirm(t *testing.T) {
+	key := convo.Key("channel", "user")
+	st := expenseFlow.Begin(key)
+	st.Title, st.Category, st.Amount, st.People, st.Wallet = "ディナー", "ぜいたくごはん", 3000, 3, "おひ財布"
+	st.Editing = true
+	st.SetStep(stepExpenseAmount)
+	expenseFlow.Sessions.Put(key, st)
+	defer expenseFlow.Cancel(key)
+
+	expenseFlow.Handle(key, convo.Input{Text: "3500"}, func(*discordgo.MessageSend) {})
+
+	got, ok := expenseFlow.Sessions.Get(key)
+	if !ok {
+		t.Fatal("conversation should continue")
+	}
+	if got.CurrentStep() != stepExpenseConfirm || got.People != 3 {
+		t.Fatalf("step = %q, people = %d, want %q with 3 people", got.CurrentStep(), got.People, stepExpenseConfirm)
+	}
+}
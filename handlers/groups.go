@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+
+	"pyonchi/internal/split"
+)
+
+// loadGroups は channelID に保存されたいつものメンバーを返す
+func loadGroups(channelID string) ([]split.Group, error) {
+	var groups []split.Group
+	if _, err := loadConfig(nsGroups, channelID, &groups); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// findGroup は名前が name のいつものメンバーを探す
+func findGroup(groups []split.Group, name string) (split.Group, bool) {
+	i := slices.IndexFunc(groups, func(g split.Group) bool { return g.Name == name })
+	if i < 0 {
+		return split.Group{}, false
+	}
+	return groups[i], true
+}
+
+// groupNames は選択肢に出すいつものメンバーの名前
+func groupNames(groups []split.Group) []string {
+	var names []string
+	for _, g := range groups {
+		names = append(names, g.Name)
+	}
+	return names
+}
+
+// --- ぴょんちー グループ をハンドリングする関数 ---
+//
+//	ぴょんちー グループ                         一覧を見る
+//	ぴょんちー グループ いつものメンバー @a @b 2 一人ずつの重み付きで保存する
+//	ぴょんちー グループ削除 いつものメンバー     消す
+func GroupHandle(s *discordgo.Session, m *discordgo.MessageCreate) {
+	reply := messageReply(s, m.ChannelID)
+	name, args, _ := ParseCommand(m.Content)
+
+	// 読んでから保存するまでは updateConfig のロックの中でやる
+	var groups []split.Group
+	switch {
+	case name == CommandGroupDelete:
+		if len(args) != 1 {
+			reply.Text("⚠️ `ぴょんちー グループ削除 名前` で消せるよ")
+			return
+		}
+		err := updateConfig(nsGroups, m.ChannelID, &groups, func() error {
+			n := len(groups)
+			groups = slices.DeleteFunc(groups, func(g split.Group) bool { return g.Name == args[0] })
+			if len(groups) == n {
+				return errors.New(args[0] + " は保存されてないよ")
+			}
+			return nil
+		})
+		if err != nil {
+			reply.Text("⚠️ " + err.Error())
+			return
+		}
+		reply.Text("🗑 " + args[0] + " を消したよ")
+
+	case len(args) == 0:
+		groups, err := loadGroups(m.ChannelID)
+		if err != nil {
+			reply.Text("⚠️ " + err.Error())
+			return
+		}
+		reply.Text(groupListText(groups))
+
+	case len(args) == 1:
+		reply.Text("⚠️ `ぴょんちー グループ 名前 @メンバー 重み ...` で保存できるよ")
+
+	default:
+		members, err := split.ParseGroupMembers(args[1:])
+		if err != nil {
+			reply.Text("⚠️ " + err.Error())
+			return
+		}
+		g := split.Group{Name: args[0], Members: members}
+		err = updateConfig(nsGroups, m.ChannelID, &groups, func() error {
+			if i := slices.IndexFunc(groups, func(old split.Group) bool { return old.Name == g.Name }); i >= 0 {
+				groups[i] = g
+			} else {
+				groups = append(groups, g)
+			}
+			return nil
+		})
+		if err != nil {
+			reply.Text("⚠️ " + err.Error())
+			return
+		}
+		reply.Text("💾 " + g.Name + " を保存したよ。割り勘で人数を聞かれたら選んでね\n" + groupMembersText(g))
+	}
+}
+
+func groupListText(groups []split.Group) string {
+	if len(groups) == 0 {
+		return "いつものメンバーはまだないよ。`ぴょんちー グループ 名前 @メンバー 重み ...` で保存できるよ"
+	}
+	var b strings.Builder
+	b.WriteString("👥 いつものメンバー\n")
+	for _, g := range groups {
+		b.WriteString("**" + g.Name + "**\n" + groupMembersText(g))
+	}
+	return b.String()
+}
+
+func groupMembersText(g split.Group) string {
+	var b strings.Builder
+	for _, m := range g.Members {
+		b.WriteString("・" + m.Name + " (重み " + strconv.FormatFloat(m.Weight, 'f', -1, 64) + ")\n")
+	}
+	return b.String()
+}
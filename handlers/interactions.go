@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"github.com/bwmarrin/discordgo"
+
+	"pyonchi/internal/convo"
+)
+
+// ボタン・プルダウン・モーダルの CustomID に入れる操作の名前
+const (
+	actionCancel           = "cancel"
+	actionExpenseCategory  = "exp_category"
+	actionExpenseWallet    = "exp_wallet"
+	actionExpenseConfirm   = "exp_confirm"
+	actionExpenseForm      = "exp_form"
+	actionExpenseFormModal = "exp_form_modal"
+	actionReceiptReview    = "rcpt_review"
+	actionReceiptEditModal = "rcpt_edit_modal"
+	actionReceiptWallet    = "rcpt_wallet"
+	actionSplitMode        = "split_mode"
+	actionSplitClaim       = "split_claim"
+	actionSplitClose       = "split_close"
+	actionSplitRounding    = "split_rounding"
+	actionSplitGroup       = "split_group"
+	actionSplitAdjust      = "split_adjust"
+	actionSplitTrip        = "split_trip"
+	actionGroupSplit       = "group"
+	actionGroupPaidForm    = "group_paid_form"
+	actionGroupPaidModal   = "group_paid_modal"
+	actionSettlePaid       = "settle_paid"
+)
+
+type componentHandler func(s *discordgo.Session, i *discordgo.InteractionCreate, t convo.Target)
+
+// componentRoute は操作名ごとの処理先
+// shared なら会話を始めた人以外も操作できる
+type componentRoute struct {
+	handle componentHandler
+	shared bool
+}
+
+// componentRoutes は CustomID の操作名ごとの処理先
+var componentRoutes = map[string]componentRoute{
+	actionCancel:           {handle: cancelByButton},
+	actionExpenseCategory:  {handle: handleExpenseComponent},
+	actionExpenseWallet:    {handle: handleExpenseComponent},
+	actionExpenseConfirm:   {handle: handleExpenseComponent},
+	actionExpenseForm:      {handle: handleExpenseComponent},
+	actionExpenseFormModal: {handle: handleExpenseComponent},
+	actionReceiptReview:    {handle: handleReceiptComponent},
+	actionReceiptEditModal: {handle: handleReceiptComponent},
+	actionReceiptWallet:    {handle: handleReceiptComponent},
+	actionSplitMode:        {handle: handleSplitComponent},
+	actionSplitClaim:       {handle: handleSplitComponent, shared: true},
+	actionSplitClose:       {handle: handleSplitComponent},
+	actionSplitRounding:    {handle: handleSplitComponent},
+	actionSplitGroup:       {handle: handleSplitComponent},
+	actionSplitAdjust:      {handle: handleSplitComponent},
+	actionSplitTrip:        {handle: handleSplitComponent},
+	actionGroupSplit:       {handle: handleGroupComponent, shared: true},
+	actionGroupPaidForm:    {handle: handleGroupComponent, shared: true},
+	actionGroupPaidModal:   {handle: handleGroupComponent, shared: true},
+	actionSettlePaid:       {handle: handleSettleComponent, shared: true},
+}
+
+// --- すべてのインタラクションをハンドリングする関数 ---
+func InteractionHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	var customID string
+	switch i.Type {
+	case discordgo.InteractionApplicationCommand:
+		handleCommand(s, i)
+		return
+	case discordgo.InteractionApplicationCommandAutocomplete:
+		handleAutocomplete(s, i)
+		return
+	case discordgo.InteractionMessageComponent:
+		customID = i.MessageComponentData().CustomID
+	case discordgo.InteractionModalSubmit:
+		customID = i.ModalSubmitData().CustomID
+	default:
+		return
+	}
+
+	t, ok := convo.ParseCustomID(customID)
+	if !ok {
+		return
+	}
+	route, ok := componentRoutes[t.Action]
+	if !ok {
+		return
+	}
+
+	// みんなで操作するもの以外は、会話を始めた本人の操作しか受け付けない
+	if !route.shared && t.UserID() != interactionUserID(i) {
+		interactionReply(s, i).Private("⚠️ それは他の人の操作だよ")
+		return
+	}
+
+	route.handle(s, i, t)
+}
+
+// interactionUserID はサーバーでも DM でも操作したユーザーの ID を返す
+func interactionUserID(i *discordgo.InteractionCreate) string {
+	if i.Member != nil && i.Member.User != nil {
+		return i.Member.User.ID
+	}
+	if i.User != nil {
+		return i.User.ID
+	}
+	return ""
+}
@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"pyonchi/internal/convo"
+	"pyonchi/internal/ledger"
+)
+
+// 台帳の保存先の名前空間。台帳はチャンネルごとに 1 冊
+const nsLedger = "ledger"
+
+// 精算ボタンは 1 メッセージに 5 行 x 5 個まで
+const maxSettleButtons = 25
+
+var (
+	ledgerStore convo.Store = convo.NewMemoryStore()
+	ledgerMu    sync.Mutex
+)
+
+// SetLedgerStore は貸し借りの台帳の保存先を差し替える
+func SetLedgerStore(st convo.Store) {
+	ledgerMu.Lock()
+	defer ledgerMu.Unlock()
+	ledgerStore = st
+}
+
+// updateLedger は channelID の台帳を読み込んで fn で書き換え、保存する
+func updateLedger(channelID string, fn func(l *ledger.Ledger) error) error {
+	ledgerMu.Lock()
+	defer ledgerMu.Unlock()
+
+	l, err := loadLedger(channelID)
+	if err != nil {
+		return err
+	}
+	if err := fn(l); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(l)
+	if err != nil {
+		return fmt.Errorf("failed to encode ledger: %w", err)
+	}
+	if err := ledgerStore.Put(nsLedger, channelID, b); err != nil {
+		return fmt.Errorf("failed to save ledger: %w", err)
+	}
+	return nil
+}
+
+// readLedger は channelID の台帳を読み込む
+func readLedger(channelID string) (*ledger.Ledger, error) {
+	ledgerMu.Lock()
+	defer ledgerMu.Unlock()
+	return loadLedger(channelID)
+}
+
+func loadLedger(channelID string) (*ledger.Ledger, error) {
+	l := &ledger.Ledger{}
+	b, ok, err := ledgerStore.Get(nsLedger, channelID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load ledger: %w", err)
+	}
+	if !ok {
+		return l, nil
+	}
+	if err := json.Unmarshal(b, l); err != nil {
+		return nil, fmt.Errorf("failed to decode ledger: %w", err)
+	}
+	return l, nil
+}
+
+// recordSplit は key の人が立て替えた割り勘を台帳につけて、返事に書き足す文言を返す
+// 精算できるのは Discord のユーザーだけなので、メンションでない名前と立て替えた本人の分はつけない
+func recordSplit(key string, parties []string, amounts []int, note string) string {
+	channelID, payerID := convo.ParseKey(key)
+	payer := mention(payerID)
+
+	var debtors []string
+	var owed []int
+	unnamed := false
+	for i, p := range parties {
+		if !isMentionArg(p) {
+			unnamed = true
+			continue
+		}
+		if p != payer {
+			debtors = append(debtors, p)
+			owed = append(owed, amounts[i])
+		}
+	}
+	if len(debtors) == 0 {
+		return ""
+	}
+
+	err := updateLedger(channelID, func(l *ledger.Ledger) error {
+		l.RecordSplit(payer, debtors, owed, note, time.Now())
+		return nil
+	})
+	if err != nil {
+		return "\n⚠️ 貸し借りの記録に失敗したよ: " + err.Error()
+	}
+	msg := "\n📒 " + strings.Join(debtors, " ") + " の分を貸し借りにつけといたよ。`ぴょんちー 精算` で清算できるよ"
+	if unnamed {
+		msg += "\n(メンションじゃない人の分はつけてないよ)"
+	}
+	return msg
+}
+
+func mention(userID string) string {
+	return "<@" + userID + ">"
+}
+
+// isMentionOf は m が userID のメンションかどうか (ニックネーム形式の <@!id> も含む)
+func isMentionOf(m, userID string) bool {
+	return m == mention(userID) || m == "<@!"+userID+">"
+}
+
+func mentions(userIDs []string) string {
+	var ms []string
+	for _, id := range userIDs {
+		ms = append(ms, mention(id))
+	}
+	return strings.Join(ms, " ")
+}
+
+// --- ぴょんちー 精算 をハンドリングする関数 ---
+func SettleHandle(s *discordgo.Session, m *discordgo.MessageCreate) {
+	messageReply(s, m.ChannelID)(settleMessage(m.ChannelID, m.Author.ID))
+}
+
+// settleMessage は精算の送金一覧と、払ったことにするボタンを作る
+// ボタンには台帳の Rev を入れて、記録が変わったあとの古いボタンを断る
+func settleMessage(channelID, userID string) *discordgo.MessageSend {
+	l, err := readLedger(channelID)
+	if err != nil {
+		return &discordgo.MessageSend{Content: "⚠️ " + err.Error()}
+	}
+
+	transfers := l.Settle()
+	if len(transfers) == 0 {
+		return &discordgo.MessageSend{Content: "🎉 貸し借りはないよ"}
+	}
+
+	var b strings.Builder
+	b.WriteString("💸 こう払えば精算できるよ\n")
+	var buttons []discordgo.MessageComponent
+	for i, t := range transfers {
+		n := strconv.Itoa(i + 1)
+		b.WriteString(n + ". " + t.From + " → " + t.To + " **" + strconv.Itoa(t.Amount) + "円**\n")
+		if i < maxSettleButtons {
+			buttons = append(buttons, discordgo.Button{
+				Label: n + " を払った",
+				Style: discordgo.SuccessButton,
+				CustomID: convo.Target{
+					Action: actionSettlePaid,
+					Value:  strconv.Itoa(i),
+					Key:    convo.Key(channelID, userID),
+					Nonce:  strconv.Itoa(l.Rev),
+				}.CustomID(),
+			})
+		}
+	}
+
+	if len(buttons) > 0 {
+		b.WriteString("受け取った人がボタンを押してね\n")
+	}
+
+	var rows []discordgo.MessageComponent
+	for len(buttons) > 0 {
+		n := min(5, len(buttons))
+		rows = append(rows, discordgo.ActionsRow{Components: buttons[:n]})
+		buttons = buttons[n:]
+	}
+	return &discordgo.MessageSend{Content: b.String(), Components: rows}
+}
+
+// --- 精算の 払った ボタンをハンドリングする関数 ---
+// 借りてる人が自分で消せないように、受け取る側が押したときだけ記録する
+func handleSettleComponent(s *discordgo.Session, i *discordgo.InteractionCreate, t convo.Target) {
+	reply := interactionReply(s, i)
+	channelID, _ := convo.ParseKey(t.Key)
+	clicker := interactionUserID(i)
+
+	var paid ledger.Transfer
+	err := updateLedger(channelID, func(l *ledger.Ledger) error {
+		if strconv.Itoa(l.Rev) != t.Nonce {
+			return errStaleSettle
+		}
+		transfers := l.Settle()
+		idx, err := strconv.Atoi(t.Value)
+		if err != nil || idx < 0 || idx >= len(transfers) {
+			return errStaleSettle
+		}
+		paid = transfers[idx]
+		if !isMentionOf(paid.To, clicker) {
+			return errNotCreditor
+		}
+		l.RecordPayment(paid.From, paid.To, paid.Amount, time.Now())
+		return nil
+	})
+	if err == errStaleSettle {
+		reply.Private("⚠️ そのボタンは古いよ。もう一回 `ぴょんちー 精算` してね")
+		return
+	}
+	if err == errNotCreditor {
+		reply.Private("⚠️ 払ったのを記録できるのは受け取る " + paid.To + " だけだよ")
+		return
+	}
+	if err != nil {
+		reply.Text("⚠️ " + err.Error())
+		return
+	}
+
+	reply.Text("✅ " + paid.From + " → " + paid.To + " " + strconv.Itoa(paid.Amount) + "円 を払ったことにしたよ")
+	reply(settleMessage(channelID, interactionUserID(i)))
+}
+
+var (
+	errStaleSettle = errors.New("stale settle button")
+	errNotCreditor = errors.New("settle button pressed by non-creditor")
+)
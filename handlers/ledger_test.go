@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"testing"
+
+	"pyonchi/internal/convo"
+)
+
+func TestRecordSplitOnlyMentions(t *testing.T) {
+	key := convo.Key("ledger-test", "payer")
+	recordSplit(key, []string{"たろう", "<@a>", "<@payer>"}, []int{1000, 2000, 3000}, "割り勘")
+
+	l, err := readLedger("ledger-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l.Entries) != 1 {
+		t.Fatalf("Entries = %+v", l.Entries)
+	}
+	if e := l.Entries[0]; e.Creditor != "<@payer>" || e.Debtor != "<@a>" || e.Amount != 2000 {
+		t.Fatalf("Entries[0] = %+v", e)
+	}
+}
+
+func TestIsMentionOf(t *testing.T) {
+	for _, m := range []string{"<@a>", "<@!a>"} {
+		if !isMentionOf(m, "a") {
+			t.Errorf("isMentionOf(%q, a) = false", m)
+		}
+	}
+	if isMentionOf("<@b>", "a") {
+		t.Error("isMentionOf(<@b>, a) = true")
+	}
+}
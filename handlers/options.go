@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"pyonchi/notion"
+)
+
+// categorySharedMeal は何人分かまとめて払うカテゴリ。人数を聞いて台帳にもつける
+// 処理で名前を使っているので、Notion の選択肢から消えていないか起動時に確かめる
+const categorySharedMeal = "ぜいたくごはん"
+
+// requiredCategories は Notion の選択肢にないと困るカテゴリ
+var requiredCategories = []string{categorySharedMeal}
+
+// Notion から取れなかったときに使うカテゴリと財布
+var (
+	defaultExpenseCategories = []string{"いつもごはん", categorySharedMeal, "日用品", "住居費", "旅行", "その他"}
+	defaultExpenseWallets    = []string{"おひ財布", "ぽよ財布", "B/43"}
+)
+
+// プルダウンに出せる選択肢の上限 (Discord の制限)
+const maxSelectOptions = 25
+
+// expenseOptions は Notion の「カテゴリ」「財布」の選択肢のキャッシュ
+var expenseOptions = struct {
+	mu         sync.RWMutex
+	categories []string
+	wallets    []string
+}{
+	categories: defaultExpenseCategories,
+	wallets:    defaultExpenseWallets,
+}
+
+// expenseCategories はいまのカテゴリの選択肢
+func expenseCategories() []string {
+	expenseOptions.mu.RLock()
+	defer expenseOptions.mu.RUnlock()
+	return expenseOptions.categories
+}
+
+// expenseWallets はいまの財布の選択肢
+func expenseWallets() []string {
+	expenseOptions.mu.RLock()
+	defer expenseOptions.mu.RUnlock()
+	return expenseOptions.wallets
+}
+
+// CheckNotionSchema は家計簿データベースの定義を取ってきて、列と処理で使うカテゴリが揃っているかを確かめる
+// 同じ定義からカテゴリと財布の選択肢も取り込むので、起動時はこれだけ呼べばいい
+func CheckNotionSchema() (notion.SchemaReport, error) {
+	db, err := client.RetrieveDatabase()
+	if err != nil {
+		return notion.SchemaReport{}, err
+	}
+	setExpenseOptions(client.ExpenseOptions(db))
+	return client.CheckSchema(db, requiredCategories, nil), nil
+}
+
+// RefreshExpenseOptions は Notion からカテゴリと財布の選択肢を取り直す
+func RefreshExpenseOptions() error {
+	db, err := client.RetrieveDatabase()
+	if err != nil {
+		return err
+	}
+	setExpenseOptions(client.ExpenseOptions(db))
+	return nil
+}
+
+// setExpenseOptions は選択肢を差し替える
+// 空だったときは、いまの選択肢のままにする
+func setExpenseOptions(categories, wallets []string) {
+	expenseOptions.mu.Lock()
+	defer expenseOptions.mu.Unlock()
+	if len(categories) > 0 {
+		expenseOptions.categories = limitOptions(categories)
+	}
+	if len(wallets) > 0 {
+		expenseOptions.wallets = limitOptions(wallets)
+	}
+}
+
+// RunExpenseOptionsRefresher は interval ごとに選択肢を取り直す
+// ctx が終わるまでブロックするので goroutine で呼ぶ
+func RunExpenseOptionsRefresher(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := RefreshExpenseOptions(); err != nil {
+				log.Printf("RefreshExpenseOptions error: %v", err)
+			}
+		}
+	}
+}
+
+func limitOptions(options []string) []string {
+	if len(options) > maxSelectOptions {
+		log.Printf("選択肢が %d 個あるので先頭の %d 個だけ使う", len(options), maxSelectOptions)
+		return options[:maxSelectOptions]
+	}
+	return options
+}
+
+// --- 選択肢の取り直しをハンドリングする関数 ---
+//
+//	ぴょんちー 選択肢更新
+//
+// サーバーの管理権限を持っている人だけが使える
+func OptionsRefreshHandle(s *discordgo.Session, m *discordgo.MessageCreate) {
+	reply := messageReply(s, m.ChannelID)
+
+	perms, err := s.UserChannelPermissions(m.Author.ID, m.ChannelID)
+	if err != nil || perms&discordgo.PermissionManageServer == 0 {
+		reply.Text("⚠️ 選択肢の更新はサーバーの管理者しかできないよ")
+		return
+	}
+
+	if err := RefreshExpenseOptions(); err != nil {
+		log.Println(err)
+		reply.Text("⚠️ Notion から選択肢が取れなかった")
+		return
+	}
+	reply.Text(optionsText())
+}
+
+func optionsText() string {
+	return "🔄 選択肢を更新したよ\n" +
+		"カテゴリ: " + strings.Join(expenseCategories(), " / ") + "\n" +
+		"財布: " + strings.Join(expenseWallets(), " / ")
+}
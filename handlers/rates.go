@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+
+	"pyonchi/internal/currency"
+)
+
+// 為替レートは全チャンネル共通の 1 つの表
+const (
+	nsRates  = "rates"
+	ratesKey = "jpy"
+)
+
+// loadRates は登録されている為替レートを返す
+func loadRates() (currency.Rates, error) {
+	rates := currency.Rates{}
+	if _, err := loadConfig(nsRates, ratesKey, &rates); err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
+
+// toJPY は m を登録されているレートで円にする
+func toJPY(m currency.Money) (int, error) {
+	if m.IsJPY() {
+		return int(m.Amount), nil
+	}
+	rates, err := loadRates()
+	if err != nil {
+		return 0, err
+	}
+	return rates.ToJPY(m)
+}
+
+// originalNote は外貨のときだけ " (12.5 USD)" を返す
+func originalNote(m currency.Money) string {
+	if m.IsJPY() {
+		return ""
+	}
+	return " (" + m.String() + ")"
+}
+
+// --- ぴょんちー レート をハンドリングする関数 ---
+//
+//	ぴょんちー レート          一覧を見る
+//	ぴょんちー レート USD 150  1 USD = 150 円で登録する
+func RateHandle(s *discordgo.Session, m *discordgo.MessageCreate) {
+	reply := messageReply(s, m.ChannelID)
+	_, args, _ := ParseCommand(m.Content)
+
+	if len(args) == 0 {
+		rates, err := loadRates()
+		if err != nil {
+			reply.Text("⚠️ " + err.Error())
+			return
+		}
+		reply.Text(ratesText(rates))
+		return
+	}
+	if len(args) != 2 || len(args[0]) != 3 {
+		reply.Text("⚠️ `ぴょんちー レート USD 150` みたいに、通貨コードと 1 単位あたりの円を書いてね")
+		return
+	}
+
+	code := strings.ToUpper(args[0])
+	rate, err := strconv.ParseFloat(args[1], 64)
+	if err != nil || !currency.ValidAmount(rate) || code == currency.JPY {
+		reply.Text("⚠️ レートが変じゃない？")
+		return
+	}
+	rates := currency.Rates{}
+	err = updateConfig(nsRates, ratesKey, &rates, func() error {
+		rates[code] = rate
+		return nil
+	})
+	if err != nil {
+		reply.Text("⚠️ " + err.Error())
+		return
+	}
+	reply.Text("💱 1 " + code + " = " + args[1] + "円 にしたよ")
+}
+
+func ratesText(rates currency.Rates) string {
+	if len(rates) == 0 {
+		return "レートはまだないよ。`ぴょんちー レート USD 150` で登録できるよ"
+	}
+	codes := make([]string, 0, len(rates))
+	for code := range rates {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	var b strings.Builder
+	b.WriteString("💱 登録してあるレート\n")
+	for _, code := range codes {
+		b.WriteString("・1 " + code + " = " + strconv.FormatFloat(rates[code], 'f', -1, 64) + "円\n")
+	}
+	return b.String()
+}
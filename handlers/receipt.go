@@ -0,0 +1,358 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"pyonchi/gemini"
+	"pyonchi/internal/convo"
+	"pyonchi/internal/currency"
+)
+
+type ReceiptData struct {
+	convo.Cursor
+	Merchant string `json:"merchant"`
+	Category string `json:"category"`
+	Amount   int    `json:"amount"`
+	Date     string `json:"date"`
+	Wallet   string `json:"wallet"`
+
+	// Reviewed は解析結果をユーザーが確認したら true
+	Reviewed bool `json:"reviewed"`
+}
+
+var geminiClient *gemini.Client
+
+func SetGeminiClient(cli *gemini.Client) {
+	geminiClient = cli
+}
+
+// レシート読み取りのステップ
+const (
+	stepReceiptImage  = "image"
+	stepReceiptReview = "review"
+	stepReceiptWallet = "wallet"
+)
+
+// 解析結果の確認画面のボタンの値
+const (
+	reviewActionOK   = "ok"
+	reviewActionEdit = "edit"
+)
+
+var receiptFlow = &convo.Flow[*ReceiptData]{
+	New:   func() *ReceiptData { return &ReceiptData{} },
+	First: stepReceiptImage,
+	Steps: map[string]*convo.Step[*ReceiptData]{
+		stepReceiptImage: {
+			Prompt: func(st *ReceiptData) *discordgo.MessageSend {
+				return prompt(st, "レシートの画像を送ってね")
+			},
+			Parse: parseReceiptImage,
+			Next:  convo.Then[*ReceiptData](stepReceiptReview),
+		},
+		// --- 解析結果を見せて、間違っていたらモーダルで直してもらう ---
+		stepReceiptReview: {
+			Prompt: receiptReviewPrompt,
+			Parse: func(st *ReceiptData, in convo.Input) error {
+				if in.Fields != nil {
+					st.Reviewed = false
+					return applyReceiptEdits(st, in.Fields)
+				}
+				switch strings.TrimSpace(in.Text) {
+				case reviewActionOK, "OK", "この内容でOK":
+					if _, err := time.Parse("2006-01-02", st.Date); err != nil {
+						return errors.New("日付が読み取れてないから修正してね")
+					}
+					// AI が Notion にないカテゴリを返すこともあるので、選択肢にあるか確かめる
+					if _, err := parseOption(st.Category, expenseCategories(), "カテゴリ"); err != nil {
+						return errors.New("カテゴリ「" + st.Category + "」はリストにないから、「修正する」から直してね (" + strings.Join(expenseCategories(), " / ") + ")")
+					}
+					st.Reviewed = true
+					return nil
+				default:
+					return errors.New("ボタンで選んでよね")
+				}
+			},
+			Next: func(st *ReceiptData) string {
+				if st.Reviewed {
+					return stepReceiptWallet
+				}
+				return stepReceiptReview
+			},
+		},
+		stepReceiptWallet: {
+			Prompt: func(st *ReceiptData) *discordgo.MessageSend {
+				return selectPrompt(st, "どの財布から払ったの？", actionReceiptWallet, "支払い財布を選んでよね", expenseWallets())
+			},
+			Parse: func(st *ReceiptData, in convo.Input) error {
+				wallet, err := parseOption(in.Text, expenseWallets(), "財布")
+				if err != nil {
+					return err
+				}
+				st.Wallet = wallet
+				return nil
+			},
+		},
+	},
+	Done: func(_ string, st *ReceiptData, reply convo.Reply) {
+		dateTime, err := time.Parse("2006-01-02", st.Date)
+		if err != nil {
+			reply.Text("⚠️ 日付の解析に失敗したよ")
+			return
+		}
+
+		// Notion に書き込み
+		err = client.CreateExpenseRecord(st.Merchant, st.Category, st.Amount, 1, st.Wallet, dateTime, currency.JPY, 0)
+		if err != nil {
+			reply.Text("⚠️ Notion に記録できなかった")
+			return
+		}
+
+		reply.Text("🍽 家計簿つけたよ\n" +
+			"タイトル: " + st.Merchant + "\n" +
+			"一人あたり: " + strconv.Itoa(st.Amount) + "円\n" +
+			"人数: " + strconv.Itoa(1) + "人\n" +
+			"合計: " + strconv.Itoa(st.Amount*1) + "円\n" +
+			"財布: " + st.Wallet + "\n\n" +
+			getBudgetText(reply, st.Category))
+	},
+}
+
+// レシート画像から家計簿記録を行う会話中かどうかを判定
+func IsInExpenseReceiptConversation(key string) bool {
+	return receiptFlow.Active(key)
+}
+
+// レシート画像から家計簿記録を行うハンドラ
+func ExpenseReceiptHandleOngoing(s *discordgo.Session, m *discordgo.MessageCreate) {
+	key := convo.Key(m.ChannelID, m.Author.ID)
+
+	// 画像が送られてきたら、その画像で会話を始め直す
+	if len(m.Attachments) > 0 {
+		receiptFlow.BeginWith(key, messageInput(m), messageReply(s, m.ChannelID))
+		return
+	}
+	receiptFlow.Handle(key, messageInput(m), messageReply(s, m.ChannelID))
+}
+
+// --- レシート読み取りのボタンやプルダウンをハンドリングする関数 ---
+func handleReceiptComponent(s *discordgo.Session, i *discordgo.InteractionCreate, t convo.Target) {
+	reply := interactionReply(s, i)
+
+	switch t.Action {
+	case actionReceiptReview:
+		if t.Value == reviewActionEdit {
+			openReceiptEditModal(s, i, t)
+			return
+		}
+		receiptFlow.Answer(t, stepReceiptReview, componentInput(i, t), reply)
+	case actionReceiptEditModal:
+		receiptFlow.Answer(t, stepReceiptReview, modalInput(i), reply)
+	case actionReceiptWallet:
+		receiptFlow.Answer(t, stepReceiptWallet, componentInput(i, t), reply)
+	}
+}
+
+// receiptReviewPrompt は解析結果と確認ボタンを出す
+func receiptReviewPrompt(st *ReceiptData) *discordgo.MessageSend {
+	return &discordgo.MessageSend{
+		Content: "レシートを読んでみたよ。合ってる？",
+		Embeds: []*discordgo.MessageEmbed{
+			{
+				Title: "🧾 " + st.Merchant,
+				Fields: []*discordgo.MessageEmbedField{
+					{Name: "カテゴリ", Value: st.Category, Inline: true},
+					{Name: "金額", Value: strconv.Itoa(st.Amount) + "円", Inline: true},
+					{Name: "日付", Value: st.Date, Inline: true},
+				},
+			},
+		},
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.Button{Label: "この内容でOK", Style: discordgo.SuccessButton, CustomID: convo.CustomID(actionReceiptReview, reviewActionOK, st)},
+					discordgo.Button{Label: "修正する", Style: discordgo.SecondaryButton, CustomID: convo.CustomID(actionReceiptReview, reviewActionEdit, st)},
+				},
+			},
+			cancelButtonRow(st),
+		},
+	}
+}
+
+// openReceiptEditModal は解析結果を入れた修正用のモーダルを開く
+func openReceiptEditModal(s *discordgo.Session, i *discordgo.InteractionCreate, t convo.Target) {
+	st, ok := receiptFlow.Lookup(t, stepReceiptReview)
+	if !ok {
+		interactionReply(s, i).Private("⚠️ その操作はもう受け付けてないよ")
+		return
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseModal,
+		Data: &discordgo.InteractionResponseData{
+			CustomID: convo.CustomID(actionReceiptEditModal, "", st),
+			Title:    "レシートの内容を修正",
+			Components: []discordgo.MessageComponent{
+				textInputRow("merchant", "店舗名", "", st.Merchant),
+				textInputRow("category", "カテゴリ", strings.Join(expenseCategories(), " / "), st.Category),
+				textInputRow("amount", "金額", "", strconv.Itoa(st.Amount)),
+				textInputRow("date", "日付", "YYYY-MM-DD", st.Date),
+			},
+		},
+	})
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// applyReceiptEdits はモーダルで直した値を検証して反映する
+func applyReceiptEdits(st *ReceiptData, fields map[string]string) error {
+	merchant := strings.TrimSpace(fields["merchant"])
+	if merchant == "" {
+		return errors.New("店舗名を入れてよね")
+	}
+	category, err := parseOption(fields["category"], expenseCategories(), "カテゴリ")
+	if err != nil {
+		return err
+	}
+	amount, err := strconv.Atoi(strings.TrimSpace(fields["amount"]))
+	if err != nil || amount <= 0 {
+		return errors.New("金額は整数にしてよね")
+	}
+	date := strings.TrimSpace(fields["date"])
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		return errors.New("日付は YYYY-MM-DD にしてよね")
+	}
+
+	st.Merchant = merchant
+	st.Category = category
+	st.Amount = amount
+	st.Date = date
+	return nil
+}
+
+// parseReceiptImage は受け取ったレシート画像を Gemini で解析する
+// 読めなかったら会話は終わりにして、画像を送り直してもらう
+func parseReceiptImage(st *ReceiptData, in convo.Input) error {
+	if in.AttachmentURL == "" {
+		return convo.Fail("レシートの画像を送ってよね")
+	}
+
+	// 画像を一時ファイルにダウンロード
+	imagePath, err := downloadImageToTempFile(in.AttachmentURL)
+	if err != nil {
+		return convo.Fail("画像のダウンロードに失敗したよ")
+	}
+	defer os.Remove(imagePath)
+
+	// 画像が横長の場合は縦長に回転させる
+	if err := rotateImageIfLandscape(imagePath); err != nil {
+		return convo.Fail("画像の回転に失敗したよ")
+	}
+
+	// Gemini API を使ってレシートデータを取得
+	receiptData, err := geminiClient.GetReceiptData(imagePath)
+	if errors.Is(err, gemini.ErrRateLimitExceeded) {
+		return convo.Fail("AI の利用制限超えちゃった")
+	}
+	if err != nil {
+		return convo.Fail("レシートの解析に失敗したよ")
+	}
+
+	st.Merchant = receiptData.Merchant
+	st.Category = receiptData.Category
+	st.Amount = receiptData.Amount
+	st.Date = receiptData.Date
+	return nil
+}
+
+func downloadImageToTempFile(url string) (string, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	tmpFile, err := os.CreateTemp("", "receipt_*.jpg")
+	if err != nil {
+		return "", err
+	}
+	defer tmpFile.Close()
+
+	_, err = io.Copy(tmpFile, resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	return tmpFile.Name(), nil
+}
+
+// rotateImageIfLandscape は画像が横長の場合に90度回転させる
+func rotateImageIfLandscape(imagePath string) error {
+	// 画像ファイルを開く
+	file, err := os.Open(imagePath)
+	if err != nil {
+		return fmt.Errorf("failed to open image: %w", err)
+	}
+	defer file.Close()
+
+	// 画像をデコード
+	img, format, err := image.Decode(file)
+	if err != nil {
+		return fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	bounds := img.Bounds()
+	width := bounds.Dx()
+	height := bounds.Dy()
+
+	// 横長でない場合は何もしない
+	if width <= height {
+		return nil
+	}
+
+	// 90度回転（時計回りに回転）
+	rotated := image.NewRGBA(image.Rect(0, 0, height, width))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			rotated.Set(height-1-y, x, img.At(x, y))
+		}
+	}
+
+	// 回転した画像を元のファイルに上書き保存
+	outFile, err := os.Create(imagePath)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer outFile.Close()
+
+	// フォーマットに応じてエンコード
+	switch format {
+	case "jpeg", "jpg":
+		err = jpeg.Encode(outFile, rotated, &jpeg.Options{Quality: 95})
+	case "png":
+		err = png.Encode(outFile, rotated)
+	default:
+		// デフォルトはJPEGとして保存
+		err = jpeg.Encode(outFile, rotated, &jpeg.Options{Quality: 95})
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to encode image: %w", err)
+	}
+
+	return nil
+}
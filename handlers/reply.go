@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"log"
+
+	"github.com/bwmarrin/discordgo"
+
+	"pyonchi/internal/convo"
+)
+
+// messageReply はチャンネルにメッセージを送る Reply
+func messageReply(s *discordgo.Session, channelID string) convo.Reply {
+	return func(msg *discordgo.MessageSend) {
+		// 本人にだけ見せる返事はチャンネルでは送れないので普通に送る
+		msg.Flags &^= discordgo.MessageFlagsEphemeral
+		if _, err := s.ChannelMessageSendComplex(channelID, msg); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// interactionReply はインタラクションに応答する Reply
+// 応答は 1 回しかできないので、2 回目以降はフォローアップとして送る
+func interactionReply(s *discordgo.Session, i *discordgo.InteractionCreate) convo.Reply {
+	responded := false
+	return func(msg *discordgo.MessageSend) {
+		if responded {
+			_, err := s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
+				Content:    msg.Content,
+				Components: msg.Components,
+				Embeds:     msg.Embeds,
+				Flags:      msg.Flags,
+			})
+			if err != nil {
+				log.Println(err)
+			}
+			return
+		}
+
+		responded = true
+		err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content:    msg.Content,
+				Components: msg.Components,
+				Embeds:     msg.Embeds,
+				Flags:      msg.Flags,
+			},
+		})
+		if err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// prompt は質問をやめるボタン付きで作る
+func prompt(st convo.State, content string) *discordgo.MessageSend {
+	return &discordgo.MessageSend{
+		Content:    content,
+		Components: []discordgo.MessageComponent{cancelButtonRow(st)},
+	}
+}
+
+// selectPrompt はプルダウンとやめるボタン付きの質問を作る
+// プルダウンで選ばれると action のルートに渡される
+func selectPrompt(st convo.State, content, action, placeholder string, options []string) *discordgo.MessageSend {
+	opts := make([]discordgo.SelectMenuOption, 0, len(options))
+	for _, o := range options {
+		opts = append(opts, discordgo.SelectMenuOption{Label: o, Value: o})
+	}
+
+	return &discordgo.MessageSend{
+		Content: content,
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.SelectMenu{
+						MenuType:    discordgo.StringSelectMenu,
+						CustomID:    convo.CustomID(action, "", st),
+						Options:     opts,
+						Placeholder: placeholder,
+					},
+				},
+			},
+			cancelButtonRow(st),
+		},
+	}
+}
+
+// messageInput はメッセージを会話の入力にする
+// 画像が添付されていれば最初のものを使う
+func messageInput(m *discordgo.MessageCreate) convo.Input {
+	in := convo.Input{UserID: m.Author.ID, Text: m.Content}
+	if len(m.Attachments) > 0 {
+		in.AttachmentURL = m.Attachments[0].URL
+	}
+	return in
+}
+
+// componentInput はプルダウンやボタンの操作を会話の入力にする
+// ボタンの場合は CustomID に埋め込んだ値を使う
+func componentInput(i *discordgo.InteractionCreate, t convo.Target) convo.Input {
+	in := convo.Input{UserID: interactionUserID(i), Text: t.Value}
+	data := i.MessageComponentData()
+	if data.ComponentType == discordgo.SelectMenuComponent {
+		in.Values = data.Values
+		if len(data.Values) > 0 {
+			in.Text = data.Values[0]
+		}
+	}
+	return in
+}
+
+// modalInput はモーダルの入力欄を会話の入力にする
+func modalInput(i *discordgo.InteractionCreate) convo.Input {
+	fields := map[string]string{}
+	for _, c := range i.ModalSubmitData().Components {
+		row, ok := c.(*discordgo.ActionsRow)
+		if !ok {
+			continue
+		}
+		for _, rc := range row.Components {
+			if ti, ok := rc.(*discordgo.TextInput); ok {
+				fields[ti.CustomID] = ti.Value
+			}
+		}
+	}
+	return convo.Input{UserID: interactionUserID(i), Fields: fields}
+}
+
+// textInputRow はモーダルの 1 行分の入力欄
+func textInputRow(customID, label, placeholder, value string) discordgo.ActionsRow {
+	return discordgo.ActionsRow{
+		Components: []discordgo.MessageComponent{
+			discordgo.TextInput{
+				CustomID:    customID,
+				Label:       label,
+				Style:       discordgo.TextInputShort,
+				Placeholder: placeholder,
+				Value:       value,
+				Required:    true,
+			},
+		},
+	}
+}
+
+// deferredInteractionReply は「考え中」で先に応答してから、返事をフォローアップで送る Reply
+// レシート解析など 3 秒以上かかる処理の前に使う
+func deferredInteractionReply(s *discordgo.Session, i *discordgo.InteractionCreate) convo.Reply {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+	if err != nil {
+		log.Println(err)
+	}
+
+	return func(msg *discordgo.MessageSend) {
+		_, err := s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
+			Content:    msg.Content,
+			Components: msg.Components,
+			Embeds:     msg.Embeds,
+		})
+		if err != nil {
+			log.Println(err)
+		}
+	}
+}
@@ -3,31 +3,42 @@ package handlers
 import (
 	"github.com/bwmarrin/discordgo"
 
-	"pyonchi/gemini"
 	"pyonchi/internal/convo"
 )
 
-func RouteOngoingConversations(s *discordgo.Session, m *discordgo.MessageCreate, geminiClient *gemini.Client) bool {
+func RouteOngoingConversations(s *discordgo.Session, m *discordgo.MessageCreate) bool {
 	key := convo.Key(m.ChannelID, m.Author.ID)
 
-	// 割り勘ボットの ongoing state?
-	if IsInSplitConversation(key) {
-		SplitHandleOngoing(s, m)
-		return true
+	// やめる / キャンセル はどの会話中でも受け付ける
+	if isCancelWord(m.Content) {
+		if endConversations(key) {
+			s.ChannelMessageSend(m.ChannelID, "🛑 キャンセルしたよ")
+			return true
+		}
+		return false
 	}
 
-	// 外食ボットの ongoing state?
-	if IsInExpenseConversation(key) {
-		ExpenseManualHandleOngoing(s, m)
-		return true
+	c := activeConversation(key)
+	if c == nil {
+		return false
 	}
 
-	// レシート画像ボットの ongoing state?
-	if IsInExpenseReceiptConversation(key) {
-		ExpenseReceiptHandleOngoing(s, m, geminiClient)
+	// 最初から はいまの会話をやり直す
+	if isRestartWord(m.Content) {
+		c.Start(key, messageReply(s, m.ChannelID))
 		return true
 	}
 
-	// どちらでもない
-	return false
+	c.Handle(key, messageInput(m), messageReply(s, m.ChannelID))
+	return true
+}
+
+// activeConversation は key の進行中の会話を返す
+func activeConversation(key string) convo.Conversation {
+	for _, c := range conversations() {
+		if c.Active(key) {
+			return c
+		}
+	}
+	return nil
 }
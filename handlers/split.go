@@ -1,74 +1,555 @@
 package handlers
 
 import (
-	"math"
+	"errors"
+	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/bwmarrin/discordgo"
+
+	"pyonchi/internal/convo"
+	"pyonchi/internal/currency"
+	"pyonchi/internal/split"
 )
 
 type SplitState struct {
-	Step   int // 1 = 金額待ち, 2 = 人数待ち
-	Total  int // 合計金額
-	People int // 人数
+	convo.Cursor
+	Total  int           `json:"total"`  // 合計金額
+	People int           `json:"people"` // 人数
+	Mode   string        `json:"mode"`   // 均等か、重み・金額指定か、レシートのアイテムごとか
+	Shares []split.Share `json:"shares"` // 重み・金額指定のときの一人ずつの配分
+	Items  []split.Item  `json:"items"`  // レシートのアイテム
+	Claims []split.Claim `json:"claims"` // 誰がどのアイテムを選んだか
+	Closed bool          `json:"closed"` // アイテム選びを締め切ったら true
+	// Rounding は均等に分けるときの端数の処理
+	Rounding split.Rounding `json:"rounding"`
+	// Members はメンションで指定された参加者のユーザー ID。先頭は始めた人
+	Members []string `json:"members"`
+	// Original は外貨で払ったときの現地の合計金額。Total は円に換算した額
+	Original currency.Money `json:"original"`
+	// Adjust はサービス料・税・割引。Subtotal はかける前の金額で、Total はかけたあとの金額
+	Adjust   split.Adjustment `json:"adjust"`
+	Subtotal int              `json:"subtotal"`
+	Adjusted bool             `json:"adjusted"`
 }
 
-var splitConversationState = map[string]*SplitState{}
+// errTooManyPeople は人数が split.MaxPeople を超えたときのエラー
+var errTooManyPeople = errors.New("人数は " + strconv.Itoa(split.MaxPeople) + "人までにしてね")
 
-// 会話中かどうかを判定
-func IsInSplitConversation(key string) bool {
-	_, exists := splitConversationState[key]
-	return exists
+// 割り勘のステップ
+const (
+	stepSplitTotal    = "total"
+	stepSplitPeople   = "people"
+	stepSplitMode     = "mode"
+	stepSplitShares   = "shares"
+	stepSplitClaim    = "claim"
+	stepSplitRounding = "rounding"
+	stepSplitAdjust   = "adjust"
+)
+
+// 割り勘の分け方
+const (
+	splitModeEven   = "even"
+	splitModeCustom = "custom"
+	splitModeItems  = "items"
+	splitModeTrip   = "trip" // 何人かの立て替えを集める。チャンネルのグループ割り勘で扱う
+)
+
+var splitFlow = &convo.Flow[*SplitState]{
+	New:   func() *SplitState { return &SplitState{} },
+	First: stepSplitTotal,
+	Steps: map[string]*convo.Step[*SplitState]{
+		// --- 合計金額を受け取る ---
+		stepSplitTotal: {
+			Prompt: splitTotalPrompt,
+			Parse: func(st *SplitState, in convo.Input) error {
+				if in.AttachmentURL != "" {
+					return parseSplitReceipt(st, in.AttachmentURL)
+				}
+				return st.setTotal(in.Text)
+			},
+			Next: func(st *SplitState) string {
+				switch st.Mode {
+				case splitModeItems:
+					return stepSplitClaim
+				}
+				return stepSplitAdjust
+			},
+			Skip: func(st *SplitState) bool { return st.Total > 0 },
+		},
+		// --- サービス料・税・割引をかける ---
+		stepSplitAdjust: {
+			Prompt: splitAdjustPrompt,
+			Parse: func(st *SplitState, in convo.Input) error {
+				text := strings.TrimSpace(in.Text)
+				if text == splitAdjustNone || text == "なし" {
+					st.Adjusted = true
+					return nil
+				}
+				a, err := split.ParseAdjustment(text)
+				if err != nil {
+					return err
+				}
+				st.applyAdjustment(a)
+				return nil
+			},
+			Next: convo.Then[*SplitState](stepSplitPeople),
+			Skip: func(st *SplitState) bool { return st.Adjusted },
+		},
+		// --- 人数を受け取る ---
+		stepSplitPeople: {
+			Prompt: splitPeoplePrompt,
+			Parse: func(st *SplitState, in convo.Input) error {
+				channelID, _ := convo.ParseKey(st.Key)
+				groups, err := loadGroups(channelID)
+				if err != nil {
+					return err
+				}
+				if g, ok := findGroup(groups, strings.TrimSpace(in.Text)); ok {
+					st.applyGroup(g)
+					return nil
+				}
+
+				people, err := strconv.Atoi(in.Text)
+				if err != nil || people <= 0 {
+					return errors.New("人数が変じゃない？")
+				}
+				if people > split.MaxPeople {
+					return errTooManyPeople
+				}
+				st.People = people
+				return nil
+			},
+			Next: convo.Then[*SplitState](stepSplitMode),
+			Skip: func(st *SplitState) bool { return st.People > 0 },
+		},
+		// --- 均等に分けるか、重みや金額を指定するか ---
+		stepSplitMode: {
+			Prompt: splitModePrompt,
+			Parse: func(st *SplitState, in convo.Input) error {
+				switch strings.TrimSpace(in.Text) {
+				case splitModeEven, "均等":
+					st.Mode = splitModeEven
+				case splitModeCustom, "重み・金額指定":
+					st.Mode = splitModeCustom
+				default:
+					return errors.New("ボタンで選んでよね")
+				}
+				return nil
+			},
+			Next: func(st *SplitState) string {
+				if st.Mode == splitModeCustom {
+					return stepSplitShares
+				}
+				return stepSplitRounding
+			},
+			Skip: func(st *SplitState) bool { return st.Mode != "" },
+		},
+		// --- 均等に分けるときの端数の処理を選ぶ ---
+		stepSplitRounding: {
+			Prompt: func(st *SplitState) *discordgo.MessageSend {
+				var labels []string
+				for _, r := range split.Roundings {
+					labels = append(labels, r.Label())
+				}
+				return selectPrompt(st, "端数はどうする？", actionSplitRounding, "端数の処理を選んでね", labels)
+			},
+			Parse: func(st *SplitState, in convo.Input) error {
+				r, ok := split.RoundingByLabel(strings.TrimSpace(in.Text))
+				if !ok {
+					return errors.New("プルダウンから選んでよね")
+				}
+				st.Rounding = r
+				return nil
+			},
+			Skip: func(st *SplitState) bool { return st.Rounding.Policy != "" },
+		},
+		// --- 一人ずつの重みや金額を受け取る ---
+		stepSplitShares: {
+			Prompt: func(st *SplitState) *discordgo.MessageSend {
+				if len(st.Members) > 0 {
+					return prompt(st, "一人ずつ 1 行で重みか金額を教えて\n"+
+						"名前を書かなければ "+mentions(st.Members)+" の順だよ\n"+
+						"例:\n```\n1\n1\n3000円\n```")
+				}
+				return prompt(st, "一人ずつ 1 行で教えて\n"+
+					"`名前 重み` で比率、`名前 3000円` で金額指定だよ\n"+
+					"名前の代わりに @メンション にすると貸し借りにつけられるよ\n"+
+					"例:\n```\nたろう 1\nはなこ 1\nこども 0.5\n幹事 3000円\n```")
+			},
+			Parse: func(st *SplitState, in convo.Input) error {
+				shares, err := split.ParseShares(in.Text)
+				if err != nil {
+					return err
+				}
+				if len(shares) != st.People {
+					return errors.New(strconv.Itoa(st.People) + "人分書いてよね")
+				}
+				// 名前のない行はメンションされた人に順番に当てる
+				for i := range shares {
+					if shares[i].Unnamed && i < len(st.Members) {
+						shares[i].Name = mention(st.Members[i])
+						shares[i].Unnamed = false
+					}
+				}
+				if _, err := split.Allocate(st.Total, shares); err != nil {
+					return err
+				}
+				st.Shares = shares
+				return nil
+			},
+			Skip: func(st *SplitState) bool { return len(st.Shares) > 0 },
+		},
+		// --- みんなに自分の分のアイテムを選んでもらう ---
+		stepSplitClaim: {
+			Prompt: splitClaimPrompt,
+			Parse:  parseSplitClaim,
+		},
+	},
+	Done: func(key string, st *SplitState, reply convo.Reply) {
+		switch st.Mode {
+		case splitModeCustom:
+			reply.Text(splitBreakdownText(key, st))
+			return
+		case splitModeItems:
+			reply.Text(splitItemsText(key, st))
+			return
+		}
+
+		reply.Text(splitEvenText(key, st))
+	},
 }
 
-// 会話の続きメッセージを処理
-func SplitHandleOngoing(s *discordgo.Session, m *discordgo.MessageCreate) {
-	key := m.ChannelID + "|" + m.Author.ID
-	state, ok := splitConversationState[key]
-	if !ok {
-		state = &SplitState{
-			Step: 1,
+// splitEvenText は均等に分けた結果と、集まる額とお会計の差を誰が持つかを並べる
+// 参加者がわかっていれば一人ずつメンションして、台帳につける
+func splitEvenText(key string, st *SplitState) string {
+	amounts := st.Rounding.Even(st.Total, st.People)
+	collected := 0
+	for _, a := range amounts {
+		collected += a
+	}
+
+	var b strings.Builder
+	b.WriteString(splitAdjustText(st))
+	b.WriteString("💴 " + strconv.Itoa(st.Total) + "円" + originalNote(st.Original) + "を" + strconv.Itoa(st.People) + "人でわりかんしたら")
+	switch {
+	case len(st.Members) == st.People:
+		b.WriteString("こうじゃない？\n")
+		for i, id := range st.Members {
+			b.WriteString("・" + mention(id) + ": **" + strconv.Itoa(amounts[i]) + "円**\n")
 		}
-		splitConversationState[key] = state
+	case amounts[0] == amounts[len(amounts)-1]:
+		b.WriteString("**" + strconv.Itoa(amounts[0]) + "円** じゃない？\n")
+	case st.Rounding.Policy == split.RoundOrganizer:
+		b.WriteString("こうじゃない？\n")
+		b.WriteString("・幹事: **" + strconv.Itoa(amounts[0]) + "円**\n")
+		b.WriteString("・ほかの " + strconv.Itoa(st.People-1) + "人: **" + strconv.Itoa(amounts[1]) + "円**\n")
+	default:
+		extra := st.Total % st.People
+		b.WriteString("こうじゃない？\n")
+		b.WriteString("・最初の " + strconv.Itoa(extra) + "人: **" + strconv.Itoa(amounts[0]) + "円**\n")
+		b.WriteString("・残りの " + strconv.Itoa(st.People-extra) + "人: **" + strconv.Itoa(amounts[len(amounts)-1]) + "円**\n")
+	}
+
+	b.WriteString("(" + st.Rounding.Label() + ") 集めると " + strconv.Itoa(collected) + "円 / お会計 " + strconv.Itoa(st.Total) + "円\n")
+	switch diff := collected - st.Total; {
+	case diff > 0:
+		b.WriteString("💰 " + strconv.Itoa(diff) + "円多いから、立て替えた人がもらっていいよ")
+	case st.Rounding.Policy == split.RoundOrganizer && st.People > 1 && amounts[0] > amounts[1]:
+		b.WriteString("🙇 端数の " + strconv.Itoa(amounts[0]-amounts[1]) + "円は幹事が持ってね")
+	default:
+		b.WriteString("👌 ぴったりだよ")
+	}
+
+	if len(st.Members) == st.People {
+		var parties []string
+		for _, id := range st.Members {
+			parties = append(parties, mention(id))
+		}
+		b.WriteString(recordSplit(key, parties, amounts, "割り勘 "+strconv.Itoa(st.Total)+"円"))
+	}
+	return b.String()
+}
+
+// setTotal は合計金額を読む。外貨なら登録されているレートで円にする
+func (st *SplitState) setTotal(text string) error {
+	m, ok := currency.Parse(text)
+	if !ok {
+		return errors.New("合計金額は整数にしてよね (外貨なら `120USD` みたいに書いてね)")
+	}
+	yen, err := toJPY(m)
+	if err != nil {
+		return err
+	}
+	st.Total = yen
+	st.Original = currency.Money{}
+	if !m.IsJPY() {
+		st.Original = m
+	}
+	return nil
+}
+
+// サービス料などがないときのボタンの値
+const splitAdjustNone = "none"
+
+// splitAdjustPrompt はサービス料・税・割引があるか聞く
+func splitAdjustPrompt(st *SplitState) *discordgo.MessageSend {
+	msg := prompt(st, "サービス料や税、割引はある？\n"+
+		"`+10%` でサービス料やチップ、`税10%` で税抜き→税込み、`-500` や `-10%` で割引だよ\n"+
+		"`-500 +10% 税10%` みたいにまとめて書いてもいいよ")
+	msg.Components = append([]discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{Label: "なし", Style: discordgo.PrimaryButton, CustomID: convo.CustomID(actionSplitAdjust, splitAdjustNone, st)},
+			},
+		},
+	}, msg.Components...)
+	return msg
+}
+
+// applyAdjustment は合計金額にサービス料・税・割引をかける
+func (st *SplitState) applyAdjustment(a split.Adjustment) {
+	if st.Subtotal == 0 {
+		st.Subtotal = st.Total
+	}
+	st.Adjust = a
+	st.Total = a.Apply(st.Subtotal).Total
+	st.Adjusted = true
+}
+
+// splitAdjustText はサービス料・税・割引の内訳。何もかけてなければ ""
+func splitAdjustText(st *SplitState) string {
+	if st.Adjust.IsZero() {
+		return ""
+	}
+	r := st.Adjust.Apply(st.Subtotal)
+	parts := []string{"小計 " + strconv.Itoa(r.Subtotal) + "円"}
+	if r.Discount > 0 {
+		parts = append(parts, "割引 -"+strconv.Itoa(r.Discount)+"円")
+	}
+	if r.Surcharge > 0 {
+		parts = append(parts, "サービス料 +"+strconv.Itoa(r.Surcharge)+"円")
+	}
+	if r.Tax > 0 {
+		parts = append(parts, "税 +"+strconv.Itoa(r.Tax)+"円")
+	}
+	return "🧾 " + strings.Join(parts, " / ") + " → 合計 " + strconv.Itoa(r.Total) + "円\n"
+}
+
+// splitTotalPrompt は合計金額を聞く。何人かで立て替えたときはボタンで切り替える
+func splitTotalPrompt(st *SplitState) *discordgo.MessageSend {
+	msg := prompt(st, "全部で何円払ったの？ (レシートの画像を送ると、アイテムごとに分けられるよ)")
+	msg.Components = append([]discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{Label: "何人かで立て替えた", Style: discordgo.SecondaryButton, CustomID: convo.CustomID(actionSplitTrip, splitModeTrip, st)},
+			},
+		},
+	}, msg.Components...)
+	return msg
+}
+
+// splitPeoplePrompt は人数を聞く。いつものメンバーがあればプルダウンで選べる
+// プルダウンに載らないほどあるときは、名前を書いても選べる
+func splitPeoplePrompt(st *SplitState) *discordgo.MessageSend {
+	channelID, _ := convo.ParseKey(st.Key)
+	groups, err := loadGroups(channelID)
+	if err != nil || len(groups) == 0 {
+		return prompt(st, "何人でわりかんするの？")
+	}
+	text := "何人でわりかんするの？ いつものメンバーなら選んでね"
+	if len(groups) > maxSelectOptions {
+		text += " (ないときは名前を書いてね)"
+	}
+	return selectPrompt(st, text, actionSplitGroup, "いつものメンバー", limitOptions(groupNames(groups)))
+}
+
+// applyGroup はいつものメンバーの重みで分けることにする
+func (st *SplitState) applyGroup(g split.Group) {
+	st.People = len(g.Members)
+	st.Shares = slices.Clone(g.Members)
+	st.Mode = splitModeCustom
+}
+
+// splitModePrompt は分け方を選ぶボタンを出す
+func splitModePrompt(st *SplitState) *discordgo.MessageSend {
+	msg := prompt(st, "どうやって分ける？")
+	msg.Components = append([]discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{Label: "均等", Style: discordgo.PrimaryButton, CustomID: convo.CustomID(actionSplitMode, splitModeEven, st)},
+				discordgo.Button{Label: "重み・金額指定", Style: discordgo.SecondaryButton, CustomID: convo.CustomID(actionSplitMode, splitModeCustom, st)},
+			},
+		},
+	}, msg.Components...)
+	return msg
+}
+
+// splitBreakdownText は一人ずつの負担額を並べて、台帳につける
+func splitBreakdownText(key string, st *SplitState) string {
+	amounts, err := split.Allocate(st.Total, st.Shares)
+	if err != nil {
+		return "⚠️ " + err.Error()
+	}
+
+	var b strings.Builder
+	var parties []string
+	b.WriteString(splitAdjustText(st))
+	b.WriteString("💴 " + strconv.Itoa(st.Total) + "円" + originalNote(st.Original) + "をこう分けたらいいんじゃない？\n")
+	for i, share := range st.Shares {
+		b.WriteString("・" + share.Name + ": **" + strconv.Itoa(amounts[i]) + "円**\n")
+		parties = append(parties, share.Name)
 	}
 
-	switch state.Step {
+	// 台帳につくのはメンションされた人の分だけ
+	b.WriteString(recordSplit(key, parties, amounts, "割り勘 "+strconv.Itoa(st.Total)+"円"))
+	return b.String()
+}
 
-	// --- Step 1: 合計金額を受け取る ---
-	case 1:
-		state.Step = 2
-		s.ChannelMessageSend(m.ChannelID, "全部で何円払ったの？")
-	case 2:
-		total, err := strconv.Atoi(m.Content)
-		if err != nil || total <= 0 {
-			s.ChannelMessageSend(m.ChannelID, "⚠️ 合計金額は整数にしてよね")
+// --- 割り勘のボタンをハンドリングする関数 ---
+func handleSplitComponent(s *discordgo.Session, i *discordgo.InteractionCreate, t convo.Target) {
+	switch t.Action {
+	case actionSplitMode:
+		splitFlow.Answer(t, stepSplitMode, componentInput(i, t), interactionReply(s, i))
+	case actionSplitTrip:
+		// 自分の割り勘はやめて、チャンネルのみんなで立て替えを集める
+		reply := interactionReply(s, i)
+		if _, ok := splitFlow.Lookup(t, stepSplitTotal); !ok {
+			reply.Private("⚠️ その操作はもう受け付けてないよ")
 			return
 		}
-		state.Total = total
-		state.Step = 3
-		s.ChannelMessageSend(m.ChannelID, "何人でわりかんするの？")
-
-	// --- Step 2: 人数入力 ---
-	case 3:
-		people, err := strconv.Atoi(m.Content)
-		if err != nil || people <= 0 {
-			s.ChannelMessageSend(m.ChannelID, "⚠️ 人数が変じゃない？")
+		splitFlow.Cancel(t.Key)
+		channelID, userID := convo.ParseKey(t.Key)
+		startGroupSplit(s, channelID, userID, nil, reply)
+	case actionSplitAdjust:
+		splitFlow.Answer(t, stepSplitAdjust, componentInput(i, t), interactionReply(s, i))
+	case actionSplitGroup:
+		splitFlow.Answer(t, stepSplitPeople, componentInput(i, t), interactionReply(s, i))
+	case actionSplitRounding:
+		splitFlow.Answer(t, stepSplitRounding, componentInput(i, t), interactionReply(s, i))
+	case actionSplitClaim, actionSplitClose:
+		splitFlow.Answer(t, stepSplitClaim, componentInput(i, t), interactionReply(s, i))
+	}
+}
+
+// splitMembers は始めた人とメンションされた人のユーザー ID を返す
+// bot と重複は除く
+func splitMembers(m *discordgo.MessageCreate) []string {
+	members := []string{m.Author.ID}
+	for _, u := range m.Mentions {
+		if u.Bot || slices.Contains(members, u.ID) {
+			continue
+		}
+		members = append(members, u.ID)
+	}
+	return members
+}
+
+// 会話中かどうかを判定
+func IsInSplitConversation(key string) bool {
+	return splitFlow.Active(key)
+}
+
+// 割り勘を始める。会話中ならメッセージを続きとして処理する
+// 引数があれば、会話中でもその内容で始め直す
+func SplitHandleOngoing(s *discordgo.Session, m *discordgo.MessageCreate) {
+	key := convo.Key(m.ChannelID, m.Author.ID)
+	reply := messageReply(s, m.ChannelID)
+
+	_, args, _ := ParseCommand(m.Content)
+	if splitFlow.Active(key) && len(args) == 0 {
+		splitFlow.Handle(key, messageInput(m), reply)
+		return
+	}
+
+	groups, err := loadGroups(m.ChannelID)
+	if err != nil {
+		reply.Text("⚠️ " + err.Error())
+		return
+	}
+	st := &SplitState{}
+	if err := st.applyArgs(args, groups); err != nil {
+		reply.Text("⚠️ " + err.Error())
+		return
+	}
+
+	// メンションされた人と始めた人で割り勘する
+	if members := splitMembers(m); len(members) > 1 {
+		if st.People > 0 && st.People != len(members) {
+			reply.Text("⚠️ 人数とメンションした人の数が合わないよ")
 			return
 		}
-		state.People = people
+		st.Members = members
+		st.People = len(members)
+	}
 
-		// 計算
-		per := int(math.Ceil(float64(state.Total) / float64(state.People)))
+	// 立て替えはチャンネルのみんなで集める
+	if st.Mode == splitModeTrip {
+		if splitFlow.Active(key) {
+			splitFlow.Cancel(key)
+		}
+		startGroupSplit(s, m.ChannelID, m.Author.ID, st.Members, reply)
+		return
+	}
 
-		// 結果表示
-		msg := "💴 " +
-			strconv.Itoa(state.Total) + "円を" +
-			strconv.Itoa(state.People) + "人でわりかんしたら" +
-			"**" + strconv.Itoa(per) + "円** じゃない？"
+	// 金額がなくてレシートの画像付きならアイテムごとの割り勘にする
+	if st.Total == 0 && len(m.Attachments) > 0 {
+		splitFlow.BeginWith(key, messageInput(m), reply)
+		return
+	}
+	splitFlow.StartWith(key, st, reply)
+}
 
-		s.ChannelMessageSend(m.ChannelID, msg)
+// applyArgs は "ぴょんちー 割り勘 12000 4" の引数を埋める
+// 1 つ目の数字が合計金額、2 つ目が人数。"4人" なら順番に関係なく人数
+// "+10%" "税10%" "-500" はサービス料・税・割引
+// "立て替え" なら何人かで立て替えた分をまとめる。いつものメンバーの名前ならその人たちで分ける
+func (st *SplitState) applyArgs(args []string, groups []split.Group) error {
+	var adjust split.Adjustment
+	for _, arg := range args {
+		if adjust.ParseToken(arg) {
+			continue
+		}
+		if g, ok := findGroup(groups, arg); ok {
+			st.applyGroup(g)
+			continue
+		}
+		if isMentionArg(arg) {
+			continue
+		}
+		if arg == "立て替え" {
+			st.Mode = splitModeTrip
+			continue
+		}
+		if n, ok := parsePeopleArg(arg); ok {
+			st.People = n
+			continue
+		}
+		n, ok := parseYenArg(arg)
+		switch {
+		case st.Total == 0:
+			if err := st.setTotal(arg); err != nil {
+				return errors.New(arg + " がよくわからないよ")
+			}
+		case !ok:
+			return errors.New(arg + " がよくわからないよ")
+		case st.People == 0:
+			st.People = n
+		default:
+			return errors.New("数字が多すぎるよ")
+		}
+	}
+	if st.People > split.MaxPeople {
+		return errTooManyPeople
+	}
 
-		// 会話終了（削除）
-		delete(splitConversationState, key)
+	// 一行で金額まで書いたなら、サービス料などもそこに書いてあるものとする
+	switch {
+	case st.Total > 0:
+		st.applyAdjustment(adjust)
+	case !adjust.IsZero():
+		return errors.New("サービス料や割引を書くなら金額も書いてよね")
 	}
+	return nil
 }
@@ -0,0 +1,380 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"pyonchi/internal/convo"
+	"pyonchi/internal/currency"
+	"pyonchi/internal/ledger"
+	"pyonchi/internal/split"
+)
+
+// GroupSplitState はチャンネルのみんなで立て替えを集めるグループ割り勘
+// キーはチャンネルごとなので、誘われた人なら誰でも参加して立て替えを追加できる
+type GroupSplitState struct {
+	convo.Cursor
+	Owner     string          `json:"owner"`      // 始めた人。締め切りとやめるはこの人だけ
+	Invited   []string        `json:"invited"`    // 参加できる人。空なら誰でも
+	Members   []string        `json:"members"`    // 立て替えを分け合う人
+	Payments  []split.Payment `json:"payments"`   // みんなの立て替え
+	SummaryID string          `json:"summary_id"` // 更新し続けるまとめメッセージ
+	Closed    bool            `json:"closed"`     // 締め切ったら true
+}
+
+// グループ割り勘のステップ
+const stepGroupPayments = "payments"
+
+// グループ割り勘のボタンの値 (締め切りは splitClose)
+const (
+	groupJoin   = "join"
+	groupLeave  = "leave"
+	groupCancel = "cancel"
+)
+
+var groupFlow = &convo.Flow[*GroupSplitState]{
+	New:   func() *GroupSplitState { return &GroupSplitState{} },
+	First: stepGroupPayments,
+	Steps: map[string]*convo.Step[*GroupSplitState]{
+		// --- みんなの立て替えと参加・不参加を集める ---
+		stepGroupPayments: {
+			Prompt: groupSummaryMessage,
+			Parse:  parseGroupInput,
+		},
+	},
+	Done: func(key string, st *GroupSplitState, reply convo.Reply) {
+		reply.Text(groupSplitText(key, st))
+	},
+}
+
+// groupKey はチャンネルのグループ割り勘のキー
+func groupKey(channelID string) string {
+	return convo.Key(channelID, "")
+}
+
+// startGroupSplit は channelID でグループ割り勘を始めて、まとめメッセージを送る
+// invited が空なら誰でも参加できる
+func startGroupSplit(s *discordgo.Session, channelID, ownerID string, invited []string, reply convo.Reply) {
+	key := groupKey(channelID)
+	st := &GroupSplitState{Owner: ownerID, Members: []string{ownerID}}
+	if len(invited) > 0 {
+		st.Invited = slices.Clone(invited)
+		st.Members = slices.Clone(invited)
+	}
+
+	// まとめメッセージの ID は、ほかの操作が割り込まないように始めるのと同じロックの中で覚える
+	var summary *discordgo.Message
+	send := func(msg *discordgo.MessageSend) {
+		var err error
+		summary, err = s.ChannelMessageSendComplex(channelID, msg)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+	started := groupFlow.StartIfIdle(key, st, send, func(st *GroupSplitState) {
+		if summary != nil {
+			st.SummaryID = summary.ID
+		}
+	})
+	if !started {
+		reply.Text("⚠️ このチャンネルではもう立て替えを集めてるよ。上のまとめから参加してね")
+		return
+	}
+	if summary == nil {
+		reply.Text("⚠️ まとめメッセージが送れなかったよ")
+		groupFlow.Cancel(key)
+		return
+	}
+	reply.Private("🧳 立て替え集めを始めたよ")
+}
+
+// groupSummaryMessage はこれまでの立て替えと、参加・追加・締め切りのボタン
+func groupSummaryMessage(st *GroupSplitState) *discordgo.MessageSend {
+	return &discordgo.MessageSend{
+		Content: "🧳 " + mention(st.Owner) + " が立て替えを集めてるよ\n" +
+			"立て替えた人は「立て替えを追加」か、チャットで `ぴょんちー 立て替え 3000 ホテル` みたいに送ってね (外貨は `120USD`)",
+		Embeds: []*discordgo.MessageEmbed{groupSummaryEmbed(st)},
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.Button{Label: "立て替えを追加", Style: discordgo.PrimaryButton, CustomID: convo.CustomID(actionGroupPaidForm, "", st)},
+					discordgo.Button{Label: "参加する", Style: discordgo.SecondaryButton, CustomID: convo.CustomID(actionGroupSplit, groupJoin, st)},
+					discordgo.Button{Label: "抜ける", Style: discordgo.SecondaryButton, CustomID: convo.CustomID(actionGroupSplit, groupLeave, st)},
+				},
+			},
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.Button{Label: "締め切る", Style: discordgo.SuccessButton, CustomID: convo.CustomID(actionGroupSplit, splitClose, st)},
+					discordgo.Button{Label: "やめる", Style: discordgo.DangerButton, CustomID: convo.CustomID(actionGroupSplit, groupCancel, st)},
+				},
+			},
+		},
+	}
+}
+
+// groupSummaryEmbed は立て替えの一覧と参加者
+func groupSummaryEmbed(st *GroupSplitState) *discordgo.MessageEmbed {
+	var lines []string
+	total := 0
+	for i, p := range st.Payments {
+		lines = append(lines, strconv.Itoa(i+1)+". "+mention(p.UserID)+" "+p.Note+" "+strconv.Itoa(p.Amount)+"円")
+		total += p.Amount
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "まだないよ")
+	}
+
+	lines = append(lines, "", "参加者 ("+strconv.Itoa(len(st.Members))+"人): "+mentions(st.Members))
+	if len(st.Members) > 0 {
+		lines = append(lines, "一人あたり 約"+strconv.Itoa(total/len(st.Members))+"円")
+	}
+
+	return &discordgo.MessageEmbed{
+		Title:       "🧾 立て替え 合計 " + strconv.Itoa(total) + "円",
+		Description: strings.Join(lines, "\n"),
+	}
+}
+
+// parseGroupInput は参加・不参加、立て替えの追加、締め切りを受け付ける
+// まとめメッセージを更新するので、締め切り以外は Ack で返す
+func parseGroupInput(st *GroupSplitState, in convo.Input) error {
+	if in.Fields == nil {
+		switch in.Text {
+		case splitClose:
+			if in.UserID != st.Owner {
+				return convo.Ack("⚠️ 締め切れるのは始めた人だけだよ")
+			}
+			if len(st.Payments) == 0 {
+				return convo.Ack("⚠️ まだ立て替えがないよ")
+			}
+			if len(st.Members) == 0 {
+				return convo.Ack("⚠️ 参加者がいないよ")
+			}
+			st.Closed = true
+			return nil
+		case groupJoin:
+			if !st.canJoin(in.UserID) {
+				return convo.Ack("⚠️ 誘われてないみたい")
+			}
+			st.join(in.UserID)
+			return convo.Ack("🙋 参加したよ")
+		case groupLeave:
+			st.Members = slices.DeleteFunc(st.Members, func(id string) bool { return id == in.UserID })
+			return convo.Ack("👋 抜けたよ。立て替えた分はそのまま残るよ")
+		}
+	}
+
+	if !st.canJoin(in.UserID) {
+		return convo.Ack("⚠️ 誘われてないみたい")
+	}
+
+	amountText, note := in.Fields["amount"], in.Fields["note"]
+	if in.Fields == nil {
+		amountText, note, _ = strings.Cut(strings.TrimSpace(in.Text), " ")
+	}
+	m, ok := currency.Parse(amountText)
+	if !ok {
+		return errors.New("金額は整数にしてよね (例: `3000 ホテル`、外貨なら `120USD ホテル`)")
+	}
+	amount, err := toJPY(m)
+	if err != nil {
+		return err
+	}
+	note = strings.TrimSpace(note)
+	if note == "" {
+		note = "立て替え"
+	}
+	note += originalNote(m)
+
+	st.Payments = append(st.Payments, split.Payment{UserID: in.UserID, Amount: amount, Note: note})
+	st.join(in.UserID)
+	return convo.Ack("✅ " + note + " " + strconv.Itoa(amount) + "円 を追加したよ")
+}
+
+func (st *GroupSplitState) canJoin(userID string) bool {
+	return len(st.Invited) == 0 || userID == st.Owner || slices.Contains(st.Invited, userID)
+}
+
+func (st *GroupSplitState) join(userID string) {
+	if !slices.Contains(st.Members, userID) {
+		st.Members = append(st.Members, userID)
+	}
+}
+
+// groupSplitText は立て替えを参加者で均等に分けて、差し引きと精算の送金を並べる
+// 1 件ずつ台帳にもつけるので、ほかの貸し借りとまとめて精算できる
+func groupSplitText(key string, st *GroupSplitState) string {
+	var parties []string
+	for _, id := range st.Members {
+		parties = append(parties, mention(id))
+	}
+
+	record := func(l *ledger.Ledger) error {
+		for _, p := range st.Payments {
+			amounts, err := split.Allocate(p.Amount, split.EvenShares(len(parties)))
+			if err != nil {
+				return err
+			}
+			l.RecordSplit(mention(p.UserID), parties, amounts, p.Note, time.Now())
+		}
+		return nil
+	}
+
+	var trip ledger.Ledger
+	if err := record(&trip); err != nil {
+		return "⚠️ " + err.Error()
+	}
+
+	total, paid := 0, map[string]int{}
+	for _, p := range st.Payments {
+		total += p.Amount
+		paid[mention(p.UserID)] += p.Amount
+	}
+
+	var b strings.Builder
+	b.WriteString("🧳 立て替え " + strconv.Itoa(len(st.Payments)) + "件、合計 " + strconv.Itoa(total) + "円を" + strconv.Itoa(len(parties)) + "人で分けたよ\n")
+	balances := trip.Balances()
+	// 抜けた人も立て替えた分は差し引きに出す。map の順番は毎回変わるので並べ替える
+	var departed []string
+	for name, v := range paid {
+		if !slices.Contains(parties, name) && v > 0 {
+			departed = append(departed, name)
+		}
+	}
+	slices.Sort(departed)
+	parties = append(parties, departed...)
+	for _, p := range parties {
+		b.WriteString("・" + p + ": 払った " + strconv.Itoa(paid[p]) + "円 → 差し引き **" + signedYen(balances[p]) + "**\n")
+	}
+
+	transfers := trip.Settle()
+	if len(transfers) > 0 {
+		b.WriteString("💸 こう払えば精算できるよ\n")
+		for _, t := range transfers {
+			b.WriteString("・" + t.From + " → " + t.To + " **" + strconv.Itoa(t.Amount) + "円**\n")
+		}
+	}
+
+	channelID, _ := convo.ParseKey(key)
+	if err := updateLedger(channelID, record); err != nil {
+		b.WriteString("⚠️ 貸し借りの記録に失敗したよ: " + err.Error())
+	} else {
+		b.WriteString("📒 貸し借りにつけといたよ。払ったら `ぴょんちー 精算` から記録してね")
+	}
+	return b.String()
+}
+
+func signedYen(n int) string {
+	if n > 0 {
+		return "+" + strconv.Itoa(n) + "円"
+	}
+	return strconv.Itoa(n) + "円"
+}
+
+// --- グループ割り勘のボタンやモーダルをハンドリングする関数 ---
+func handleGroupComponent(s *discordgo.Session, i *discordgo.InteractionCreate, t convo.Target) {
+	reply := interactionReply(s, i)
+	st, ok := groupFlow.Lookup(t, stepGroupPayments)
+	if !ok {
+		reply.Private("⚠️ その操作はもう受け付けてないよ")
+		return
+	}
+	channelID, _ := convo.ParseKey(t.Key)
+	defer refreshGroupSummary(s, channelID, st.SummaryID)
+
+	switch t.Action {
+	case actionGroupPaidForm:
+		openGroupPaymentModal(s, i, st)
+	case actionGroupPaidModal:
+		groupFlow.Answer(t, stepGroupPayments, modalInput(i), reply)
+	case actionGroupSplit:
+		if t.Value == groupCancel {
+			if interactionUserID(i) != st.Owner {
+				reply.Private("⚠️ やめられるのは始めた人だけだよ")
+				return
+			}
+			groupFlow.Cancel(t.Key)
+			reply.Text("🛑 立て替え集めをやめたよ")
+			return
+		}
+		groupFlow.Answer(t, stepGroupPayments, componentInput(i, t), reply)
+	}
+}
+
+// openGroupPaymentModal は立て替えを入れるモーダルを開く
+func openGroupPaymentModal(s *discordgo.Session, i *discordgo.InteractionCreate, st *GroupSplitState) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseModal,
+		Data: &discordgo.InteractionResponseData{
+			CustomID: convo.CustomID(actionGroupPaidModal, "", st),
+			Title:    "立て替えを追加",
+			Components: []discordgo.MessageComponent{
+				textInputRow("amount", "金額", "3000", ""),
+				textInputRow("note", "内容", "ホテル", ""),
+			},
+		},
+	})
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// --- グループ割り勘への立て替えをハンドリングする関数 ---
+//
+//	ぴょんちー 立て替え 3000 ホテル
+//
+// 誘われていない人のメッセージは普通の会話として扱って、何も返さない
+func GroupPaidHandle(s *discordgo.Session, m *discordgo.MessageCreate) {
+	key := groupKey(m.ChannelID)
+	reply := messageReply(s, m.ChannelID)
+
+	st, ok := groupFlow.Sessions.Get(key)
+	if !ok {
+		reply.Text("⚠️ このチャンネルでは立て替えを集めてないよ。`ぴょんちー 割り勘 立て替え` で始めてね")
+		return
+	}
+	if !st.canJoin(m.Author.ID) {
+		return
+	}
+	_, args, _ := ParseCommand(m.Content)
+	if len(args) == 0 {
+		reply.Text("⚠️ `ぴょんちー 立て替え 3000 ホテル` みたいに金額を書いてね")
+		return
+	}
+
+	in := messageInput(m)
+	in.Text = strings.Join(args, " ")
+	groupFlow.Handle(key, in, reply)
+	refreshGroupSummary(s, m.ChannelID, st.SummaryID)
+}
+
+// refreshGroupSummary はまとめメッセージを今の内容に書き換える
+// 会話が終わっていればボタンを消す
+func refreshGroupSummary(s *discordgo.Session, channelID, summaryID string) {
+	if summaryID == "" {
+		return
+	}
+
+	edit := discordgo.NewMessageEdit(channelID, summaryID)
+	st, ok := groupFlow.Sessions.Get(groupKey(channelID))
+	if ok && st.SummaryID == summaryID {
+		msg := groupSummaryMessage(st)
+		edit.Content = &msg.Content
+		edit.Embeds = &msg.Embeds
+		edit.Components = &msg.Components
+	} else {
+		content := "🧳 立て替え集めはおしまい"
+		edit.Content = &content
+		edit.Components = &[]discordgo.MessageComponent{}
+	}
+
+	if _, err := s.ChannelMessageEditComplex(edit); err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+
+	"pyonchi/gemini"
+	"pyonchi/internal/convo"
+	"pyonchi/internal/split"
+)
+
+// プルダウン 1 つに並べられるアイテムの数と、並べるプルダウンの数
+const (
+	claimMenuSize  = 25
+	claimMenuCount = 3
+)
+
+// 締め切りボタンの値 (アイテム選びと立て替え集めで共通)
+const splitClose = "close"
+
+// parseSplitReceipt はレシート画像をアイテムごとに読み取る
+func parseSplitReceipt(st *SplitState, url string) error {
+	imagePath, err := downloadImageToTempFile(url)
+	if err != nil {
+		return errors.New("画像のダウンロードに失敗したよ")
+	}
+	defer os.Remove(imagePath)
+
+	if err := rotateImageIfLandscape(imagePath); err != nil {
+		return errors.New("画像の回転に失敗したよ")
+	}
+
+	resp, err := geminiClient.GetReceiptItems(imagePath)
+	if errors.Is(err, gemini.ErrRateLimitExceeded) {
+		return errors.New("AI の利用制限超えちゃった")
+	}
+	if err != nil {
+		return errors.New("レシートの解析に失敗したよ")
+	}
+	if len(resp.Items) > claimMenuSize*claimMenuCount {
+		return errors.New("アイテムが多すぎて選べないよ")
+	}
+
+	st.Items = st.Items[:0]
+	for _, it := range resp.Items {
+		st.Items = append(st.Items, split.Item{Name: it.Name, Amount: it.Amount, Tax: float64(it.Tax)})
+	}
+	st.Total = split.ItemsTotal(st.Items)
+	st.Mode = splitModeItems
+	return nil
+}
+
+// splitClaimPrompt はアイテムの一覧と、自分の分を選ぶプルダウンを出す
+// プルダウンは誰でも操作できて、締め切りは始めた人だけができる
+func splitClaimPrompt(st *SplitState) *discordgo.MessageSend {
+	var lines []string
+	for i, it := range st.Items {
+		lines = append(lines, strconv.Itoa(i+1)+". "+it.Name+" "+strconv.Itoa(int(it.Price()))+"円")
+	}
+
+	var rows []discordgo.MessageComponent
+	for chunk := 0; chunk*claimMenuSize < len(st.Items); chunk++ {
+		start := chunk * claimMenuSize
+		end := min(start+claimMenuSize, len(st.Items))
+
+		var opts []discordgo.SelectMenuOption
+		for i := start; i < end; i++ {
+			opts = append(opts, discordgo.SelectMenuOption{
+				Label: truncate(strconv.Itoa(i+1)+". "+st.Items[i].Name, 100),
+				Value: strconv.Itoa(i),
+			})
+		}
+
+		minValues := 0
+		rows = append(rows, discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.SelectMenu{
+					MenuType:    discordgo.StringSelectMenu,
+					CustomID:    convo.CustomID(actionSplitClaim, strconv.Itoa(chunk), st),
+					Options:     opts,
+					MinValues:   &minValues,
+					MaxValues:   len(opts),
+					Placeholder: "自分の分を選んでね (" + strconv.Itoa(start+1) + "〜" + strconv.Itoa(end) + ")",
+				},
+			},
+		})
+	}
+
+	rows = append(rows, discordgo.ActionsRow{
+		Components: []discordgo.MessageComponent{
+			discordgo.Button{Label: "締め切る", Style: discordgo.PrimaryButton, CustomID: convo.CustomID(actionSplitClose, splitClose, st)},
+			discordgo.Button{Label: "やめる", Style: discordgo.SecondaryButton, CustomID: convo.CustomID(actionCancel, "", st)},
+		},
+	})
+
+	return &discordgo.MessageSend{
+		Content: "みんな自分の分を選んでね。みんなで食べたものは選ばなくていいよ",
+		Embeds: []*discordgo.MessageEmbed{
+			{
+				Title:       "🧾 合計 " + strconv.Itoa(st.Total) + "円",
+				Description: strings.Join(lines, "\n"),
+			},
+		},
+		Components: rows,
+	}
+}
+
+// parseSplitClaim は選ばれたアイテムを記録する。締め切られたら次に進む
+func parseSplitClaim(st *SplitState, in convo.Input) error {
+	if in.Text == splitClose && in.Values == nil {
+		_, owner := convo.ParseKey(st.Key)
+		if in.UserID != owner {
+			return convo.Ack("⚠️ 締め切れるのは始めた人だけだよ")
+		}
+		if _, err := split.AllocateItems(st.Items, st.Claims); err != nil {
+			return err
+		}
+		st.Closed = true
+		return nil
+	}
+
+	chunk, err := strconv.Atoi(in.Text)
+	if len(in.Values) > 0 {
+		var idx int
+		idx, err = strconv.Atoi(in.Values[0])
+		chunk = idx / claimMenuSize
+	}
+	if err != nil {
+		return errors.New("プルダウンで選んでよね")
+	}
+
+	// そのプルダウンの範囲の選択を置き換える
+	start, end := chunk*claimMenuSize, (chunk+1)*claimMenuSize
+	claim := st.claimOf(in.UserID)
+	kept := claim.Items[:0]
+	for _, idx := range claim.Items {
+		if idx < start || idx >= end {
+			kept = append(kept, idx)
+		}
+	}
+	claim.Items = kept
+	for _, v := range in.Values {
+		idx, err := strconv.Atoi(v)
+		if err != nil || idx < start || idx >= end || idx >= len(st.Items) {
+			return errors.New("知らないアイテムが選ばれてるよ")
+		}
+		claim.Items = append(claim.Items, idx)
+	}
+
+	var names []string
+	for _, idx := range claim.Items {
+		names = append(names, st.Items[idx].Name)
+	}
+	if len(names) == 0 {
+		return convo.Ack("✅ 選んだアイテムはなしになったよ")
+	}
+	return convo.Ack("✅ " + strings.Join(names, "、") + " を選んだよ")
+}
+
+// claimOf は userID の選択を返す。まだなければ追加する
+func (st *SplitState) claimOf(userID string) *split.Claim {
+	for i := range st.Claims {
+		if st.Claims[i].UserID == userID {
+			return &st.Claims[i]
+		}
+	}
+	st.Claims = append(st.Claims, split.Claim{UserID: userID})
+	return &st.Claims[len(st.Claims)-1]
+}
+
+// splitItemsText はアイテムごとの割り勘の結果を並べて、台帳につける
+func splitItemsText(key string, st *SplitState) string {
+	amounts, err := split.AllocateItems(st.Items, st.Claims)
+	if err != nil {
+		return "⚠️ " + err.Error()
+	}
+
+	var b strings.Builder
+	b.WriteString("💴 " + strconv.Itoa(st.Total) + "円をアイテムごとに分けたよ\n")
+	var parties []string
+	for i, c := range st.Claims {
+		b.WriteString("・" + mention(c.UserID) + ": **" + strconv.Itoa(amounts[i]) + "円**\n")
+		parties = append(parties, mention(c.UserID))
+	}
+	b.WriteString("みんなで食べたものと税金は、それぞれの分に比例して分けてるよ")
+	b.WriteString(recordSplit(key, parties, amounts, "レシート割り勘 "+strconv.Itoa(st.Total)+"円"))
+	return b.String()
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
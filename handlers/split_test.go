@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+
+	"pyonchi/internal/convo"
+	"pyonchi/internal/split"
+)
+
+func TestSplitPeoplePromptLimitsGroups(t *testing.T) {
+	var groups []split.Group
+	for n := range 30 {
+		groups = append(groups, split.Group{Name: "グループ" + strconv.Itoa(n), Members: []split.Share{{Name: "<@1>", Weight: 1}}})
+	}
+	if err := writeConfig(nsGroups, "split-test", groups); err != nil {
+		t.Fatal(err)
+	}
+
+	st := &SplitState{}
+	st.Key = convo.Key("split-test", "user")
+	msg := splitPeoplePrompt(st)
+	for _, row := range msg.Components {
+		for _, c := range row.(discordgo.ActionsRow).Components {
+			if menu, ok := c.(discordgo.SelectMenu); ok && len(menu.Options) > maxSelectOptions {
+				t.Fatalf("select menu has %d options", len(menu.Options))
+			}
+		}
+	}
+}
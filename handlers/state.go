@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"pyonchi/internal/convo"
+)
+
+// 会話ステートの保存先の名前空間
+const (
+	nsSplit   = "split"
+	nsExpense = "expense"
+	nsReceipt = "receipt"
+	nsGroup   = "group"
+)
+
+// 返事がないまま conversationTTL 経ったら会話を終わらせる
+const conversationTTL = 15 * time.Minute
+
+// グループ割り勘は旅行などで何日もかけて集めるので長めにする
+const groupTTL = 14 * 24 * time.Hour
+
+func init() {
+	SetConvoStore(convo.NewMemoryStore())
+}
+
+// SetConvoStore は会話ステートの保存先を差し替える
+func SetConvoStore(st convo.Store) {
+	splitFlow.Sessions = convo.NewManager[*SplitState](st, nsSplit, conversationTTL)
+	expenseFlow.Sessions = convo.NewManager[*ExpenceState](st, nsExpense, conversationTTL)
+	receiptFlow.Sessions = convo.NewManager[*ReceiptData](st, nsReceipt, conversationTTL)
+	groupFlow.Sessions = convo.NewManager[*GroupSplitState](st, nsGroup, groupTTL)
+}
+
+// conversations は進行中かどうかを調べる順に会話を返す
+func conversations() []convo.Conversation {
+	return []convo.Conversation{splitFlow, expenseFlow, receiptFlow}
+}
+
+// RunConversationSweeper は期限切れの会話を掃除して、チャンネルに時間切れを知らせる
+// ctx が終わるまでブロックするので goroutine で呼ぶ
+func RunConversationSweeper(ctx context.Context, s *discordgo.Session) {
+	splitFlow.Sessions.OnExpire = func(key string, _ *SplitState) { notifyExpired(s, key) }
+	expenseFlow.Sessions.OnExpire = func(key string, _ *ExpenceState) { notifyExpired(s, key) }
+	receiptFlow.Sessions.OnExpire = func(key string, _ *ReceiptData) { notifyExpired(s, key) }
+	groupFlow.Sessions.OnExpire = func(key string, st *GroupSplitState) {
+		channelID, _ := convo.ParseKey(key)
+		s.ChannelMessageSend(channelID, "⏰ "+mention(st.Owner)+" の立て替え集めが時間切れになったよ")
+		refreshGroupSummary(s, channelID, st.SummaryID)
+	}
+
+	sweepers := []convo.Sweeper{groupFlow}
+	for _, c := range conversations() {
+		sweepers = append(sweepers, c)
+	}
+	convo.RunSweeper(ctx, time.Minute, sweepers...)
+}
+
+func notifyExpired(s *discordgo.Session, key string) {
+	channelID, userID := convo.ParseKey(key)
+	s.ChannelMessageSend(channelID, "<@"+userID+"> ⏰ 時間切れだよ。もう一回最初からやってね")
+}
@@ -1,5 +1,13 @@
 package convo
 
+import "strings"
+
 func Key(channelID, userID string) string {
 	return channelID + "|" + userID
 }
+
+// ParseKey は Key で作ったキーをチャンネル ID とユーザー ID に分ける
+func ParseKey(key string) (channelID, userID string) {
+	channelID, userID, _ = strings.Cut(key, "|")
+	return channelID, userID
+}
@@ -0,0 +1,52 @@
+package convo
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+)
+
+// Target はボタンやプルダウンの CustomID に埋め込んだ操作の宛先
+// CustomID は "<action>:<value>:<channelID>|<userID>:<nonce>" の形にする
+type Target struct {
+	Action string
+	Value  string
+	Key    string
+	Nonce  string
+}
+
+// UserID は会話を始めたユーザーの ID を返す
+func (t Target) UserID() string {
+	_, userID := ParseKey(t.Key)
+	return userID
+}
+
+// CustomID は st の会話に紐づいた CustomID を作る
+// value はボタンごとの値など、なければ空でいい
+func CustomID(action, value string, st State) string {
+	c := st.cursor()
+	return Target{Action: action, Value: value, Key: c.Key, Nonce: c.Nonce}.CustomID()
+}
+
+// CustomID は t を CustomID にする
+// 会話に紐づかないボタンは、Target を直接組み立ててこれを使う
+func (t Target) CustomID() string {
+	return strings.Join([]string{t.Action, t.Value, t.Key, t.Nonce}, ":")
+}
+
+// ParseCustomID は CustomID から宛先を取り出す
+func ParseCustomID(id string) (Target, bool) {
+	parts := strings.Split(id, ":")
+	if len(parts) != 4 || parts[0] == "" || parts[2] == "" {
+		return Target{}, false
+	}
+	return Target{Action: parts[0], Value: parts[1], Key: parts[2], Nonce: parts[3]}, true
+}
+
+// newNonce は会話ごとの使い捨ての ID を作る
+// 前の会話で送ったボタンが押されても、今の会話と区別できるようにする
+func newNonce() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,291 @@
+package convo
+
+import (
+	"errors"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// State は Flow で扱う会話ステート
+// Cursor を埋め込めば実装できる
+type State interface {
+	CurrentStep() string
+	SetStep(name string)
+	cursor() *Cursor
+}
+
+// Cursor は会話がいまどのステップにいるかを持つ
+type Cursor struct {
+	Step  string `json:"step"`
+	Key   string `json:"key"`
+	Nonce string `json:"nonce"`
+}
+
+func (c *Cursor) CurrentStep() string { return c.Step }
+func (c *Cursor) SetStep(name string) { c.Step = name }
+func (c *Cursor) cursor() *Cursor     { return c }
+
+// Input はユーザーからの入力
+// メッセージならその本文と添付、プルダウンやボタンなら選ばれた値が Text に入る
+// 複数選べるプルダウンなら選ばれた値すべてが Values に入る
+// モーダルの場合は入力欄の ID ごとの値が Fields に入る
+type Input struct {
+	UserID        string
+	Text          string
+	Values        []string
+	AttachmentURL string
+	Fields        map[string]string
+}
+
+// Ack は入力を受け取ったけれど、次のステップには進まないときに Parse が返す
+// ステートは保存され、文言は操作した本人にだけ返される
+type Ack string
+
+func (a Ack) Error() string { return string(a) }
+
+// Fail はこれ以上会話を続けられないときに Parse が返す
+// 会話は消され、文言はそのままユーザーに見せる
+type Fail string
+
+func (f Fail) Error() string { return string(f) }
+
+// Reply はユーザーに返事を送る関数
+type Reply func(msg *discordgo.MessageSend)
+
+// Text は文字だけの返事を送る
+func (r Reply) Text(content string) {
+	r(&discordgo.MessageSend{Content: content})
+}
+
+// Private は操作した本人にだけ見える返事を送る
+// チャンネルへのメッセージでは普通の返事になる
+func (r Reply) Private(content string) {
+	r(&discordgo.MessageSend{Content: content, Flags: discordgo.MessageFlagsEphemeral})
+}
+
+// Step は会話の 1 ステップ
+type Step[S State] struct {
+	// Prompt はこのステップでユーザーに聞くこと。nil なら何も送らない
+	Prompt func(st S) *discordgo.MessageSend
+	// Parse は入力を検証して st に反映する。返したエラーの文言はそのままユーザーに見せる
+	Parse func(st S, in Input) error
+	// Next は次のステップ名を返す。"" なら会話はおしまい
+	Next func(st S) string
+	// Skip が true を返すステップは、もう答えがわかっているものとして聞かずに飛ばす
+	Skip func(st S) bool
+}
+
+// Then は常に name に進む Next を返す
+func Then[S State](name string) func(st S) string {
+	return func(S) string { return name }
+}
+
+// Flow はステップを宣言して組み立てる会話
+type Flow[S State] struct {
+	// New は会話を始めるときの空のステート
+	New func() S
+	// First は最初のステップ名
+	First string
+	Steps map[string]*Step[S]
+	// Done は最後のステップを終えたときに呼ばれる。呼ばれたあと会話は消える
+	Done func(key string, st S, reply Reply)
+
+	Sessions *Manager[S]
+}
+
+// Conversation は Flow を型パラメータなしで扱うためのインターフェース
+type Conversation interface {
+	Active(key string) bool
+	Owns(t Target) bool
+	Start(key string, reply Reply)
+	Handle(key string, in Input, reply Reply)
+	Answer(t Target, step string, in Input, reply Reply)
+	Cancel(key string)
+	Sweeper
+}
+
+// Active は key の会話が進行中かどうかを返す
+func (f *Flow[S]) Active(key string) bool {
+	return f.Sessions.Exists(key)
+}
+
+// Owns は t が進行中の会話で送ったボタンなどを指しているかどうかを返す
+func (f *Flow[S]) Owns(t Target) bool {
+	st, ok := f.Sessions.Get(t.Key)
+	return ok && st.cursor().Nonce == t.Nonce
+}
+
+// Lookup は t が指す会話のステートを返す
+// 会話が終わっていたり、前の会話のボタンだった場合は false
+func (f *Flow[S]) Lookup(t Target, step string) (S, bool) {
+	st, ok := f.Sessions.Get(t.Key)
+	if !ok || st.cursor().Nonce != t.Nonce || st.CurrentStep() != step {
+		var zero S
+		return zero, false
+	}
+	return st, true
+}
+
+// Start は会話を最初から始めて、最初のステップの質問を送る
+// 進行中の会話があれば捨ててやり直す
+func (f *Flow[S]) Start(key string, reply Reply) {
+	f.StartWith(key, f.New(), reply)
+}
+
+// StartWith は途中まで埋まったステートで会話を始める
+// 答えがわかっているステップは Skip で飛ばされる
+func (f *Flow[S]) StartWith(key string, st S, reply Reply) {
+	defer f.Sessions.Lock(key)()
+	f.init(key, st)
+	f.advance(key, st, f.First, reply)
+}
+
+// StartIfIdle は key の会話が進行中でなければ st で始める。始めたら true
+// started は最初の質問を送ったあと同じロックの中で呼ばれ、そこでの st の変更も保存される
+func (f *Flow[S]) StartIfIdle(key string, st S, reply Reply, started func(st S)) bool {
+	defer f.Sessions.Lock(key)()
+	if f.Sessions.Exists(key) {
+		return false
+	}
+	f.init(key, st)
+	f.advance(key, st, f.First, reply)
+	if started != nil && f.Sessions.Exists(key) {
+		started(st)
+		f.Sessions.Put(key, st)
+	}
+	return true
+}
+
+// Begin は質問を送らずに会話を始める
+func (f *Flow[S]) Begin(key string) S {
+	defer f.Sessions.Lock(key)()
+	return f.begin(key)
+}
+
+// BeginWith は会話を始めて、最初のステップに in を渡す
+// 最初の入力を受け取ったメッセージで会話を始めたいときに使う。間にほかの操作が割り込まないよう同じロックの中でやる
+func (f *Flow[S]) BeginWith(key string, in Input, reply Reply) {
+	defer f.Sessions.Lock(key)()
+	f.handle(key, f.begin(key), in, reply)
+}
+
+func (f *Flow[S]) begin(key string) S {
+	st := f.New()
+	f.init(key, st)
+	st.SetStep(f.First)
+	f.Sessions.Put(key, st)
+	return st
+}
+
+func (f *Flow[S]) init(key string, st S) {
+	c := st.cursor()
+	c.Key = key
+	c.Nonce = newNonce()
+}
+
+// Handle は入力をいまのステップに渡して会話を進める
+func (f *Flow[S]) Handle(key string, in Input, reply Reply) {
+	defer f.Sessions.Lock(key)()
+
+	st, ok := f.Sessions.Get(key)
+	if !ok {
+		reply.Text("⚠️ 進行中の会話が見つからなかった。最初からやり直してね")
+		return
+	}
+	f.handle(key, st, in, reply)
+}
+
+// Answer は t のボタンやプルダウンで step に答える
+// 別の会話のものや、もう終わったステップのものなら古い操作として断る
+func (f *Flow[S]) Answer(t Target, step string, in Input, reply Reply) {
+	defer f.Sessions.Lock(t.Key)()
+
+	st, ok := f.Lookup(t, step)
+	if !ok {
+		reply.Private("⚠️ その操作はもう受け付けてないよ")
+		return
+	}
+	f.handle(t.Key, st, in, reply)
+}
+
+// Cancel は会話を終わらせる
+func (f *Flow[S]) Cancel(key string) {
+	defer f.Sessions.Lock(key)()
+	f.Sessions.Delete(key)
+}
+
+func (f *Flow[S]) Sweep() {
+	f.Sessions.Sweep()
+}
+
+func (f *Flow[S]) handle(key string, st S, in Input, reply Reply) {
+	step, ok := f.Steps[st.CurrentStep()]
+	if !ok {
+		reply.Text("⚠️ なんか変な状態になっちゃった")
+		f.Sessions.Delete(key)
+		return
+	}
+
+	if step.Parse != nil {
+		err := step.Parse(st, in)
+		var ack Ack
+		if errors.As(err, &ack) {
+			f.Sessions.Put(key, st)
+			reply.Private(string(ack))
+			return
+		}
+		var fail Fail
+		if errors.As(err, &fail) {
+			f.Sessions.Delete(key)
+			reply.Text("⚠️ " + string(fail))
+			return
+		}
+		if err != nil {
+			reply.Text("⚠️ " + err.Error())
+			return
+		}
+	}
+
+	f.advance(key, st, nextStep(step, st), reply)
+}
+
+// advance は next に進んで質問を送る。next が "" なら会話を終える
+func (f *Flow[S]) advance(key string, st S, next string, reply Reply) {
+	for next != "" {
+		step, ok := f.Steps[next]
+		if !ok || step.Skip == nil || !step.Skip(st) {
+			break
+		}
+		st.SetStep(next)
+		next = nextStep(step, st)
+	}
+
+	if next == "" {
+		f.Sessions.Delete(key)
+		if f.Done != nil {
+			f.Done(key, st, reply)
+		}
+		return
+	}
+
+	st.SetStep(next)
+	f.Sessions.Put(key, st)
+	f.prompt(st, reply)
+}
+
+func nextStep[S State](step *Step[S], st S) string {
+	if step.Next == nil {
+		return ""
+	}
+	return step.Next(st)
+}
+
+func (f *Flow[S]) prompt(st S, reply Reply) {
+	step, ok := f.Steps[st.CurrentStep()]
+	if !ok || step.Prompt == nil {
+		return
+	}
+	if msg := step.Prompt(st); msg != nil {
+		reply(msg)
+	}
+}
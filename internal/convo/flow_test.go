@@ -0,0 +1,134 @@
+package convo
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+type flowState struct {
+	Cursor
+	Total  int `json:"total"`
+	People int `json:"people"`
+}
+
+// atoi は数字を受け取って set で st に入れる Parse を作る
+func atoi(set func(st *flowState, n int)) func(*flowState, Input) error {
+	return func(st *flowState, in Input) error {
+		n, err := strconv.Atoi(in.Text)
+		if err != nil {
+			return errors.New("数字にしてね")
+		}
+		set(st, n)
+		return nil
+	}
+}
+
+func newTestFlow(done func(st *flowState)) *Flow[*flowState] {
+	return &Flow[*flowState]{
+		New:   func() *flowState { return &flowState{} },
+		First: "total",
+		Steps: map[string]*Step[*flowState]{
+			"total": {
+				Prompt: func(*flowState) *discordgo.MessageSend { return &discordgo.MessageSend{Content: "合計は？"} },
+				Parse:  atoi(func(st *flowState, n int) { st.Total = n }),
+				Next:   Then[*flowState]("people"),
+			},
+			"people": {
+				Prompt: func(*flowState) *discordgo.MessageSend { return &discordgo.MessageSend{Content: "人数は？"} },
+				Parse:  atoi(func(st *flowState, n int) { st.People = n }),
+			},
+		},
+		Done:     func(_ string, st *flowState, _ Reply) { done(st) },
+		Sessions: NewManager[*flowState](NewMemoryStore(), "test", time.Minute),
+	}
+}
+
+func TestFlow(t *testing.T) {
+	var done *flowState
+	f := newTestFlow(func(st *flowState) { done = st })
+
+	var replies []string
+	reply := Reply(func(msg *discordgo.MessageSend) { replies = append(replies, msg.Content) })
+
+	key := Key("channel", "user")
+	f.Start(key, reply)
+	f.Handle(key, Input{Text: "abc"}, reply)
+	f.Answer(Target{Action: "people", Key: key, Nonce: "stale"}, "people", Input{Text: "3"}, reply)
+	f.Handle(key, Input{Text: "1200"}, reply)
+	f.Handle(key, Input{Text: "3"}, reply)
+
+	want := []string{"合計は？", "⚠️ 数字にしてね", "⚠️ その操作はもう受け付けてないよ", "人数は？"}
+	if len(replies) != len(want) {
+		t.Fatalf("replies = %q, want %q", replies, want)
+	}
+	for i := range want {
+		if replies[i] != want[i] {
+			t.Errorf("replies[%d] = %q, want %q", i, replies[i], want[i])
+		}
+	}
+
+	if done == nil || done.Total != 1200 || done.People != 3 {
+		t.Errorf("Done called with %+v", done)
+	}
+	if f.Active(key) {
+		t.Error("conversation should be finished")
+	}
+}
+
+func TestFlowFail(t *testing.T) {
+	f := newTestFlow(func(*flowState) {})
+	f.Steps["total"].Parse = func(*flowState, Input) error { return Fail("読めなかった") }
+
+	var replies []string
+	reply := Reply(func(msg *discordgo.MessageSend) { replies = append(replies, msg.Content) })
+
+	key := Key("channel", "user")
+	f.BeginWith(key, Input{Text: "abc"}, reply)
+	if f.Active(key) {
+		t.Fatal("Fail should end the conversation")
+	}
+	if len(replies) != 1 || replies[0] != "⚠️ 読めなかった" {
+		t.Fatalf("replies = %q", replies)
+	}
+}
+
+func TestFlowStartIfIdle(t *testing.T) {
+	f := newTestFlow(func(*flowState) {})
+	reply := Reply(func(*discordgo.MessageSend) {})
+
+	key := Key("channel", "")
+	if !f.StartIfIdle(key, &flowState{}, reply, func(st *flowState) { st.People = 4 }) {
+		t.Fatal("StartIfIdle() should start a new conversation")
+	}
+	if f.StartIfIdle(key, &flowState{}, reply, nil) {
+		t.Fatal("StartIfIdle() should not restart an active conversation")
+	}
+	if st, ok := f.Sessions.Get(key); !ok || st.People != 4 {
+		t.Fatalf("started changes should be saved: %+v", st)
+	}
+}
+
+func TestCustomID(t *testing.T) {
+	st := &flowState{Cursor: Cursor{Key: Key("channel", "user"), Nonce: "abcd1234"}}
+
+	id := CustomID("exp_confirm", "ok", st)
+	got, ok := ParseCustomID(id)
+	if !ok {
+		t.Fatalf("ParseCustomID(%q) failed", id)
+	}
+	want := Target{Action: "exp_confirm", Value: "ok", Key: "channel|user", Nonce: "abcd1234"}
+	if got != want {
+		t.Errorf("ParseCustomID(%q) = %+v, want %+v", id, got, want)
+	}
+	if got.UserID() != "user" {
+		t.Errorf("UserID() = %q", got.UserID())
+	}
+
+	if _, ok := ParseCustomID("expense_wallet_select"); ok {
+		t.Error("old style CustomID should not parse")
+	}
+}
@@ -0,0 +1,167 @@
+package convo
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+)
+
+// Manager は 1 種類の会話 (split, expense など) のステートを管理する
+// MessageCreate と InteractionCreate は別 goroutine で呼ばれるので、
+// ハンドラは Lock で会話ごとに排他してから Get / Put する
+type Manager[T any] struct {
+	ns    string
+	store Store
+	ttl   time.Duration
+
+	// OnExpire は時間切れで会話を消したときに呼ばれる
+	OnExpire func(key string, v T)
+
+	mu    sync.Mutex
+	locks map[string]*keyLock
+	now   func() time.Time
+}
+
+type keyLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+type entry[T any] struct {
+	Data      T         `json:"data"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// NewManager は ns の名前空間に ttl で期限切れになる会話を保存する Manager を作る
+func NewManager[T any](st Store, ns string, ttl time.Duration) *Manager[T] {
+	return &Manager[T]{
+		ns:    ns,
+		store: st,
+		ttl:   ttl,
+		locks: map[string]*keyLock{},
+		now:   time.Now,
+	}
+}
+
+// Lock は key の会話を排他し、解除する関数を返す
+func (m *Manager[T]) Lock(key string) (unlock func()) {
+	m.mu.Lock()
+	l, ok := m.locks[key]
+	if !ok {
+		l = &keyLock{}
+		m.locks[key] = l
+	}
+	l.refs++
+	m.mu.Unlock()
+
+	l.mu.Lock()
+	return func() {
+		l.mu.Unlock()
+		m.mu.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(m.locks, key)
+		}
+		m.mu.Unlock()
+	}
+}
+
+// Get は期限内の会話ステートを返す
+func (m *Manager[T]) Get(key string) (T, bool) {
+	e, ok := m.load(key)
+	if !ok || !m.now().Before(e.ExpiresAt) {
+		var zero T
+		return zero, false
+	}
+	return e.Data, true
+}
+
+// Exists は期限内の会話があるかどうかを返す
+func (m *Manager[T]) Exists(key string) bool {
+	_, ok := m.Get(key)
+	return ok
+}
+
+// Put は会話ステートを保存し、期限を延長する
+func (m *Manager[T]) Put(key string, v T) {
+	b, err := json.Marshal(entry[T]{Data: v, ExpiresAt: m.now().Add(m.ttl)})
+	if err != nil {
+		log.Printf("convo: encode %s/%s: %v", m.ns, key, err)
+		return
+	}
+	if err := m.store.Put(m.ns, key, b); err != nil {
+		log.Printf("convo: put %s/%s: %v", m.ns, key, err)
+	}
+}
+
+// Delete は会話を終了する
+func (m *Manager[T]) Delete(key string) {
+	if err := m.store.Delete(m.ns, key); err != nil {
+		log.Printf("convo: delete %s/%s: %v", m.ns, key, err)
+	}
+}
+
+// Sweep は期限切れの会話を削除し、OnExpire を呼ぶ
+func (m *Manager[T]) Sweep() {
+	keys, err := m.store.Keys(m.ns)
+	if err != nil {
+		log.Printf("convo: keys %s: %v", m.ns, err)
+		return
+	}
+
+	for _, key := range keys {
+		unlock := m.Lock(key)
+		e, ok := m.load(key)
+		expired := ok && !m.now().Before(e.ExpiresAt)
+		if expired {
+			m.Delete(key)
+		}
+		unlock()
+
+		if expired && m.OnExpire != nil {
+			m.OnExpire(key, e.Data)
+		}
+	}
+}
+
+func (m *Manager[T]) load(key string) (entry[T], bool) {
+	var e entry[T]
+	b, ok, err := m.store.Get(m.ns, key)
+	if err != nil {
+		log.Printf("convo: get %s/%s: %v", m.ns, key, err)
+		return e, false
+	}
+	if !ok {
+		return e, false
+	}
+	if err := json.Unmarshal(b, &e); err != nil {
+		// 読めないステートは期限切れ扱いにして Sweep で掃除する
+		log.Printf("convo: decode %s/%s: %v", m.ns, key, err)
+		return entry[T]{}, true
+	}
+	return e, true
+}
+
+// Sweeper は期限切れの会話を掃除できるもの
+type Sweeper interface {
+	Sweep()
+}
+
+// RunSweeper は ctx が終わるまで interval ごとに Sweep を呼ぶ
+func RunSweeper(ctx context.Context, interval time.Duration, sweepers ...Sweeper) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, s := range sweepers {
+				s.Sweep()
+			}
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package convo
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testState struct {
+	Step  int    `json:"step"`
+	Title string `json:"title"`
+}
+
+func TestManagerFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "convo.json")
+	key := Key("channel", "user")
+
+	st, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	NewManager[*testState](st, "expense", time.Minute).Put(key, &testState{Step: 2, Title: "ランチ"})
+
+	t.Run("再オープンしても残っている", func(t *testing.T) {
+		reopened, err := OpenFileStore(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, ok := NewManager[*testState](reopened, "expense", time.Minute).Get(key)
+		if !ok {
+			t.Fatal("state not found")
+		}
+		if got.Step != 2 || got.Title != "ランチ" {
+			t.Errorf("Get() = %+v", got)
+		}
+	})
+
+	t.Run("削除したら消える", func(t *testing.T) {
+		NewManager[*testState](st, "expense", time.Minute).Delete(key)
+		reopened, err := OpenFileStore(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok, _ := reopened.Get("expense", key); ok {
+			t.Error("deleted key still exists")
+		}
+	})
+}
+
+func TestManagerSweep(t *testing.T) {
+	now := time.Now()
+	m := NewManager[*testState](NewMemoryStore(), "split", time.Minute)
+	m.now = func() time.Time { return now }
+
+	var expired []string
+	m.OnExpire = func(key string, _ *testState) { expired = append(expired, key) }
+
+	m.Put("a|1", &testState{Step: 1})
+	now = now.Add(30 * time.Second)
+	m.Put("b|2", &testState{Step: 1})
+	now = now.Add(45 * time.Second)
+
+	if m.Exists("a|1") {
+		t.Error("a|1 should be expired")
+	}
+	if !m.Exists("b|2") {
+		t.Error("b|2 should be alive")
+	}
+
+	m.Sweep()
+	if len(expired) != 1 || expired[0] != "a|1" {
+		t.Errorf("OnExpire called with %v", expired)
+	}
+	if keys, _ := m.store.Keys("split"); len(keys) != 1 {
+		t.Errorf("keys after sweep = %v", keys)
+	}
+}
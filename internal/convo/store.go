@@ -0,0 +1,157 @@
+package convo
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Store は進行中の会話ステートを保存するバックエンド
+// ns は会話の種類 (split, expense など)、key は Key() で作ったキー
+type Store interface {
+	Get(ns, key string) ([]byte, bool, error)
+	Put(ns, key string, data []byte) error
+	Delete(ns, key string) error
+	Keys(ns string) ([]string, error)
+}
+
+// MemoryStore はプロセス内だけで保持する Store
+type MemoryStore struct {
+	mu   sync.RWMutex
+	data map[string]map[string][]byte
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{data: map[string]map[string][]byte{}}
+}
+
+func (s *MemoryStore) Get(ns, key string) ([]byte, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.data[ns][key]
+	return v, ok, nil
+}
+
+func (s *MemoryStore) Put(ns, key string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.data[ns] == nil {
+		s.data[ns] = map[string][]byte{}
+	}
+	s.data[ns][key] = data
+	return nil
+}
+
+func (s *MemoryStore) Delete(ns, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.data[ns], key)
+	return nil
+}
+
+func (s *MemoryStore) Keys(ns string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := make([]string, 0, len(s.data[ns]))
+	for k := range s.data[ns] {
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// FileStore は JSON ファイルに書き出す Store
+// 更新のたびにファイル全体を書き換えるので、再起動しても会話を再開できる
+type FileStore struct {
+	mu   sync.Mutex
+	path string
+	data map[string]map[string]json.RawMessage
+}
+
+// OpenFileStore は path のファイルを読み込んで FileStore を作る
+// ファイルがなければ空の状態から始める
+func OpenFileStore(path string) (*FileStore, error) {
+	s := &FileStore{
+		path: path,
+		data: map[string]map[string]json.RawMessage{},
+	}
+
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read store: %w", err)
+	}
+	if len(b) == 0 {
+		return s, nil
+	}
+	if err := json.Unmarshal(b, &s.data); err != nil {
+		return nil, fmt.Errorf("failed to decode store: %w", err)
+	}
+	return s, nil
+}
+
+func (s *FileStore) Get(ns, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.data[ns][key]
+	return v, ok, nil
+}
+
+func (s *FileStore) Put(ns, key string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.data[ns] == nil {
+		s.data[ns] = map[string]json.RawMessage{}
+	}
+	s.data[ns][key] = data
+	return s.flush()
+}
+
+func (s *FileStore) Delete(ns, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.data[ns][key]; !ok {
+		return nil
+	}
+	delete(s.data[ns], key)
+	return s.flush()
+}
+
+func (s *FileStore) Keys(ns string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]string, 0, len(s.data[ns]))
+	for k := range s.data[ns] {
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// flush は一時ファイルに書いてから rename するので、途中で落ちても壊れない
+func (s *FileStore) flush() error {
+	b, err := json.Marshal(s.data)
+	if err != nil {
+		return fmt.Errorf("failed to encode store: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write store: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to replace store: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package currency
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// JPY は円の通貨コード
+const JPY = "JPY"
+
+// Money は通貨つきの金額
+type Money struct {
+	Currency string  `json:"currency"`
+	Amount   float64 `json:"amount"`
+}
+
+// IsJPY は円かどうか
+func (m Money) IsJPY() bool {
+	return m.Currency == "" || m.Currency == JPY
+}
+
+func (m Money) String() string {
+	return strconv.FormatFloat(m.Amount, 'f', -1, 64) + " " + m.Currency
+}
+
+// 記号と通貨コードの対応
+var symbols = map[string]string{
+	"¥": JPY,
+	"￥": JPY,
+	"円": JPY,
+	"$": "USD",
+	"€": "EUR",
+	"£": "GBP",
+	"₩": "KRW",
+	"元": "CNY",
+}
+
+// Parse は "1200" "1200円" "¥1,200" "$12.5" "12.5USD" "eur10" を読む
+// 通貨がなければ円にする
+func Parse(s string) (Money, bool) {
+	s = strings.ReplaceAll(strings.TrimSpace(s), ",", "")
+	cur := JPY
+
+	for sym, code := range symbols {
+		if v, ok := strings.CutPrefix(s, sym); ok {
+			s, cur = v, code
+			break
+		}
+		if v, ok := strings.CutSuffix(s, sym); ok {
+			s, cur = v, code
+			break
+		}
+	}
+	if len(s) > 3 && isCode(s[:3]) {
+		s, cur = s[3:], strings.ToUpper(s[:3])
+	} else if len(s) > 3 && isCode(s[len(s)-3:]) {
+		s, cur = s[:len(s)-3], strings.ToUpper(s[len(s)-3:])
+	}
+
+	v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || !ValidAmount(v) {
+		return Money{}, false
+	}
+	if cur == JPY && v != math.Trunc(v) {
+		return Money{}, false
+	}
+	return Money{Currency: cur, Amount: v}, true
+}
+
+func isCode(s string) bool {
+	for _, r := range s {
+		if (r < 'A' || r > 'Z') && (r < 'a' || r > 'z') {
+			return false
+		}
+	}
+	return true
+}
+
+// Rates は 1 単位あたり何円かの表
+type Rates map[string]float64
+
+// ToJPY は m を円に換算する。1 円未満は四捨五入
+func (r Rates) ToJPY(m Money) (int, error) {
+	if m.IsJPY() {
+		return int(m.Amount), nil
+	}
+	rate, ok := r[m.Currency]
+	if !ok {
+		return 0, fmt.Errorf("%s のレートが登録されてないよ。`ぴょんちー レート %s 150` みたいに登録してね", m.Currency, m.Currency)
+	}
+	yen := math.Round(m.Amount * rate)
+	if !ValidAmount(yen) {
+		return 0, fmt.Errorf("%s は円にすると大きすぎるよ", m)
+	}
+	return int(yen), nil
+}
+
+// MaxAmount は受け付ける金額の上限。これより大きいと int にしたときにおかしくなりうる
+const MaxAmount = 1e10
+
+// ValidAmount は v が 0 より大きく MaxAmount 以下の有限の数かどうかを返す
+// NaN は比べると常に false になるので、ここで弾かれる
+func ValidAmount(v float64) bool {
+	return v > 0 && v <= MaxAmount
+}
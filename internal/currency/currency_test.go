@@ -0,0 +1,40 @@
+package currency
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Money
+	}{
+		{"1200", Money{Currency: JPY, Amount: 1200}},
+		{"¥1,200", Money{Currency: JPY, Amount: 1200}},
+		{"1200円", Money{Currency: JPY, Amount: 1200}},
+		{"$12.5", Money{Currency: "USD", Amount: 12.5}},
+		{"12.5USD", Money{Currency: "USD", Amount: 12.5}},
+		{"eur10", Money{Currency: "EUR", Amount: 10}},
+	}
+	for _, tt := range tests {
+		got, ok := Parse(tt.in)
+		if !ok || got != tt.want {
+			t.Errorf("Parse(%q) = %+v, %v, want %+v", tt.in, got, ok, tt.want)
+		}
+	}
+
+	for _, in := range []string{"", "ランチ", "12.5", "-3", "USD", "USDNaN", "NaN", "Inf", "1e30", "USD1e300"} {
+		if _, ok := Parse(in); ok {
+			t.Errorf("Parse(%q) should fail", in)
+		}
+	}
+}
+
+func TestToJPY(t *testing.T) {
+	rates := Rates{"USD": 150.25}
+	yen, err := rates.ToJPY(Money{Currency: "USD", Amount: 12.5})
+	if err != nil || yen != 1878 {
+		t.Fatalf("ToJPY() = %d, %v", yen, err)
+	}
+	if _, err := rates.ToJPY(Money{Currency: "EUR", Amount: 1}); err == nil {
+		t.Fatal("ToJPY() without rate should fail")
+	}
+}
@@ -0,0 +1,117 @@
+package ledger
+
+import (
+	"sort"
+	"time"
+)
+
+// 記録の種類
+const (
+	KindDebt    = "debt"    // 割り勘などで立て替えた分
+	KindPayment = "payment" // 精算で払った分
+)
+
+// Entry は貸し借りの記録 1 件
+// Debtor が Creditor に Amount 円借りている (精算なら Creditor が Debtor に払った) ことを表す
+// 人は "<@userID>" のメンションか、割り勘で入力された名前で表す
+type Entry struct {
+	Kind     string    `json:"kind"`
+	Creditor string    `json:"creditor"`
+	Debtor   string    `json:"debtor"`
+	Amount   int       `json:"amount"`
+	Note     string    `json:"note"`
+	At       time.Time `json:"at"`
+}
+
+// Transfer は精算のための送金 1 件
+type Transfer struct {
+	From   string
+	To     string
+	Amount int
+}
+
+// Ledger は貸し借りの台帳
+type Ledger struct {
+	Entries []Entry `json:"entries"`
+	// Rev は記録するたびに増える。古い精算ボタンを見分けるのに使う
+	Rev int `json:"rev"`
+}
+
+// RecordSplit は payer が立て替えた割り勘を記録する
+// parties[i] が amounts[i] 円を負担する。payer 本人の分は記録しない
+func (l *Ledger) RecordSplit(payer string, parties []string, amounts []int, note string, at time.Time) {
+	for i, p := range parties {
+		if p == payer || amounts[i] <= 0 {
+			continue
+		}
+		l.Entries = append(l.Entries, Entry{Kind: KindDebt, Creditor: payer, Debtor: p, Amount: amounts[i], Note: note, At: at})
+	}
+	l.Rev++
+}
+
+// RecordPayment は from が to に amount 円払ったことを記録する
+func (l *Ledger) RecordPayment(from, to string, amount int, at time.Time) {
+	l.Entries = append(l.Entries, Entry{Kind: KindPayment, Creditor: from, Debtor: to, Amount: amount, Note: "精算", At: at})
+	l.Rev++
+}
+
+// Balances は一人ずつの差し引きを返す。プラスならもらう側、マイナスなら払う側
+func (l *Ledger) Balances() map[string]int {
+	b := map[string]int{}
+	for _, e := range l.Entries {
+		b[e.Creditor] += e.Amount
+		b[e.Debtor] -= e.Amount
+	}
+	return b
+}
+
+// Settle は全員の貸し借りをなくすための送金を返す
+// 一番多く払う人から一番多くもらう人へ順に送るので、送金の回数は人数 - 1 以下になる
+func (l *Ledger) Settle() []Transfer {
+	return Settle(l.Balances())
+}
+
+// Settle は差し引き balances を清算する送金を返す
+func Settle(balances map[string]int) []Transfer {
+	type party struct {
+		name   string
+		amount int
+	}
+	var creditors, debtors []party
+	for name, v := range balances {
+		switch {
+		case v > 0:
+			creditors = append(creditors, party{name, v})
+		case v < 0:
+			debtors = append(debtors, party{name, -v})
+		}
+	}
+	byAmount := func(ps []party) {
+		sort.Slice(ps, func(i, j int) bool {
+			if ps[i].amount != ps[j].amount {
+				return ps[i].amount > ps[j].amount
+			}
+			return ps[i].name < ps[j].name
+		})
+	}
+	byAmount(creditors)
+	byAmount(debtors)
+
+	var transfers []Transfer
+	for len(creditors) > 0 && len(debtors) > 0 {
+		c, d := &creditors[0], &debtors[0]
+		amount := min(c.amount, d.amount)
+		transfers = append(transfers, Transfer{From: d.name, To: c.name, Amount: amount})
+		c.amount -= amount
+		d.amount -= amount
+		if c.amount == 0 {
+			creditors = creditors[1:]
+		}
+		if d.amount == 0 {
+			debtors = debtors[1:]
+		}
+		byAmount(creditors)
+		byAmount(debtors)
+	}
+	return transfers
+}
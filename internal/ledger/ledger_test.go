@@ -0,0 +1,38 @@
+package ledger
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSettle(t *testing.T) {
+	var l Ledger
+	now := time.Now()
+
+	// A が 3000 円を 3 人で、B が 1500 円を 3 人で、D が 800 円を B と 2 人で立て替えた
+	l.RecordSplit("A", []string{"A", "B", "C"}, []int{1000, 1000, 1000}, "ランチ", now)
+	l.RecordSplit("B", []string{"A", "B", "C"}, []int{500, 500, 500}, "おやつ", now)
+	l.RecordSplit("D", []string{"B", "D"}, []int{400, 400}, "コーヒー", now)
+
+	// A: +1500, B: -400, C: -1500, D: +400
+	want := []Transfer{
+		{From: "C", To: "A", Amount: 1500},
+		{From: "B", To: "D", Amount: 400},
+	}
+	if got := l.Settle(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Settle() = %v, want %v", got, want)
+	}
+
+	// 払った分は差し引かれる
+	l.RecordPayment("C", "A", 1500, now)
+	want = []Transfer{{From: "B", To: "D", Amount: 400}}
+	if got := l.Settle(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Settle() after payment = %v, want %v", got, want)
+	}
+
+	l.RecordPayment("B", "D", 400, now)
+	if got := l.Settle(); len(got) != 0 {
+		t.Fatalf("Settle() after all payments = %v, want none", got)
+	}
+}
@@ -0,0 +1,118 @@
+package split
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Adjustment は割り勘する前にお会計にかけるサービス料・税・割引
+// 割引 → サービス料 → 税 の順にかける
+type Adjustment struct {
+	Discount     int     `json:"discount,omitempty"`      // 割引額
+	DiscountRate float64 `json:"discount_rate,omitempty"` // 割引率 (0.1 で 10% 引き)
+	Surcharge    float64 `json:"surcharge,omitempty"`     // サービス料・チップの率
+	Tax          float64 `json:"tax,omitempty"`           // 税抜きの金額にかける税率
+}
+
+// Adjusted はお会計の内訳
+type Adjusted struct {
+	Subtotal  int
+	Discount  int
+	Surcharge int
+	Tax       int
+	Total     int
+}
+
+// IsZero は何もかけないかどうか
+func (a Adjustment) IsZero() bool {
+	return a == Adjustment{}
+}
+
+// Apply は subtotal に割引・サービス料・税をかける。1 円未満は切り捨て
+func (a Adjustment) Apply(subtotal int) Adjusted {
+	r := Adjusted{Subtotal: subtotal}
+	r.Discount = a.Discount + int(math.Floor(float64(subtotal)*a.DiscountRate))
+	if r.Discount > subtotal {
+		r.Discount = subtotal
+	}
+	base := subtotal - r.Discount
+	r.Surcharge = int(math.Floor(float64(base) * a.Surcharge))
+	r.Tax = int(math.Floor(float64(base+r.Surcharge) * a.Tax))
+	r.Total = base + r.Surcharge + r.Tax
+	return r
+}
+
+// ParseAdjustment は "+10% 税10% -500" のような並びを読む
+func ParseAdjustment(text string) (Adjustment, error) {
+	var a Adjustment
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return a, errors.New("サービス料や税、割引を書いてよね")
+	}
+	for _, f := range fields {
+		if !a.ParseToken(f) {
+			return Adjustment{}, errors.New(f + " がよくわからないよ")
+		}
+	}
+	return a, nil
+}
+
+// ParseToken は 1 つ分を読んで a に足す。読めなければ false
+//
+//	+10%  サービス10%  チップ15%   サービス料・チップ
+//	税10%  tax8%                 税抜き → 税込み
+//	-500  -10%  割引500           割引
+func (a *Adjustment) ParseToken(s string) bool {
+	switch {
+	case hasAnyPrefix(&s, "サービス料", "サービス", "チップ", "+"):
+		rate, ok := parsePercent(s)
+		if !ok {
+			return false
+		}
+		a.Surcharge += rate
+	case hasAnyPrefix(&s, "税", "tax", "TAX"):
+		rate, ok := parsePercent(s)
+		if !ok {
+			return false
+		}
+		a.Tax += rate
+	case hasAnyPrefix(&s, "割引", "-"):
+		if rate, ok := parsePercent(s); ok {
+			a.DiscountRate += rate
+			return true
+		}
+		n, err := strconv.Atoi(strings.TrimSuffix(s, "円"))
+		if err != nil || n <= 0 {
+			return false
+		}
+		a.Discount += n
+	default:
+		return false
+	}
+	return true
+}
+
+func hasAnyPrefix(s *string, prefixes ...string) bool {
+	for _, p := range prefixes {
+		if v, ok := strings.CutPrefix(*s, p); ok {
+			*s = v
+			return true
+		}
+	}
+	return false
+}
+
+// parsePercent は "10%" を 0.1 として読む
+func parsePercent(s string) (float64, bool) {
+	v, ok := strings.CutSuffix(s, "%")
+	if !ok {
+		return 0, false
+	}
+	p, err := strconv.ParseFloat(v, 64)
+	if err != nil || p <= 0 || p > 100 {
+		return 0, false
+	}
+	return p / 100, true
+}
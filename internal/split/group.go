@@ -0,0 +1,37 @@
+package split
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Group は名前を付けて保存しておく割り勘のメンバー (いつものメンバー など)
+// Members の Weight がいつもの重み
+type Group struct {
+	Name    string  `json:"name"`
+	Members []Share `json:"members"`
+}
+
+// ParseGroupMembers は "@a @b 2 たろう 0.5" のような並びを読む
+// 名前のあとに数字があればその人の重み、なければ重み 1
+func ParseGroupMembers(args []string) ([]Share, error) {
+	var members []Share
+	for _, arg := range args {
+		if w, err := strconv.ParseFloat(arg, 64); err == nil {
+			if len(members) == 0 {
+				return nil, errors.New("重みの前に名前を書いてよね")
+			}
+			if w <= 0 || !ValidWeight(w) {
+				return nil, fmt.Errorf("%s の重みが変じゃない？", members[len(members)-1].Name)
+			}
+			members[len(members)-1].Weight = w
+			continue
+		}
+		members = append(members, Share{Name: arg, Weight: 1})
+	}
+	if len(members) == 0 {
+		return nil, errors.New("メンバーを書いてよね")
+	}
+	return members, nil
+}
@@ -0,0 +1,84 @@
+package split
+
+import (
+	"errors"
+	"math"
+)
+
+// Item はレシートの 1 行
+// Tax は外税の税率で、内税なら 0
+type Item struct {
+	Name   string  `json:"name"`
+	Amount int     `json:"amount"`
+	Tax    float64 `json:"tax"`
+}
+
+// Price は税込みの金額
+func (it Item) Price() float64 {
+	return float64(it.Amount) * (1 + it.Tax)
+}
+
+// Claim は 1 人が自分の分として選んだアイテムの番号
+type Claim struct {
+	UserID string `json:"user_id"`
+	Items  []int  `json:"items"`
+}
+
+// ItemsTotal はレシートの税込み合計
+func ItemsTotal(items []Item) int {
+	var sum float64
+	for _, it := range items {
+		sum += it.Price()
+	}
+	return int(math.Round(sum))
+}
+
+// AllocateItems はアイテムごとの取り分から一人ずつの負担額を計算する
+// 複数人が選んだアイテムはその人たちで均等に、誰も選ばなかったアイテムは
+// 全員で各自の取り分に比例して分ける。税もアイテムごとに一緒に配分される
+func AllocateItems(items []Item, claims []Claim) ([]int, error) {
+	if len(claims) == 0 {
+		return nil, errors.New("まだ誰も選んでないよ")
+	}
+
+	claimants := make([]int, len(items))
+	for _, c := range claims {
+		for _, idx := range c.Items {
+			if idx < 0 || idx >= len(items) {
+				return nil, errors.New("知らないアイテムが選ばれてるよ")
+			}
+			claimants[idx]++
+		}
+	}
+
+	exact := make([]float64, len(claims))
+	var claimed float64
+	for p, c := range claims {
+		for _, idx := range c.Items {
+			exact[p] += items[idx].Price() / float64(claimants[idx])
+		}
+		claimed += exact[p]
+	}
+
+	var unclaimed float64
+	for idx, it := range items {
+		if claimants[idx] == 0 {
+			unclaimed += it.Price()
+		}
+	}
+
+	shares := make([]Share, len(claims))
+	for p := range claims {
+		w := exact[p]
+		if unclaimed > 0 {
+			if claimed > 0 {
+				w += unclaimed * exact[p] / claimed
+			} else {
+				w += unclaimed / float64(len(claims))
+			}
+		}
+		shares[p] = Share{Weight: w}
+	}
+
+	return Allocate(ItemsTotal(items), shares)
+}
@@ -0,0 +1,87 @@
+package split
+
+import "fmt"
+
+// 端数の処理のしかた
+const (
+	RoundUp        = "ceil"      // 全員が Unit 円単位で切り上げた額を払う。多い分は幹事がもらう
+	RoundOrganizer = "organizer" // 幹事以外は Unit 円単位で切り捨てた額を払い、足りない分は幹事が持つ
+	RoundFirst     = "first"     // 割り切れない分は最初の人から 1 円ずつ多く払う
+)
+
+// Rounding は均等に割り勘するときの端数の処理
+type Rounding struct {
+	Policy string `json:"policy"`
+	Unit   int    `json:"unit"`
+}
+
+// Roundings は選べる端数の処理
+var Roundings = []Rounding{
+	{Policy: RoundUp, Unit: 1},
+	{Policy: RoundUp, Unit: 10},
+	{Policy: RoundUp, Unit: 100},
+	{Policy: RoundUp, Unit: 1000},
+	{Policy: RoundOrganizer, Unit: 1},
+	{Policy: RoundOrganizer, Unit: 100},
+	{Policy: RoundFirst, Unit: 1},
+}
+
+// Label は選ぶときに見せる名前
+func (r Rounding) Label() string {
+	switch r.Policy {
+	case RoundUp:
+		return fmt.Sprintf("%d円単位で切り上げ", r.Unit)
+	case RoundOrganizer:
+		if r.Unit > 1 {
+			return fmt.Sprintf("%d円単位で切り捨てて幹事が端数を持つ", r.Unit)
+		}
+		return "幹事が端数を持つ"
+	case RoundFirst:
+		return "最初の人から1円ずつ多く払う"
+	}
+	return r.Policy
+}
+
+// RoundingByLabel は Label から端数の処理を探す
+func RoundingByLabel(label string) (Rounding, bool) {
+	for _, r := range Roundings {
+		if r.Label() == label {
+			return r, true
+		}
+	}
+	return Rounding{}, false
+}
+
+// MaxPeople は割り勘できる人数の上限
+// Even は一人ずつの金額を作るので、人数をそのまま信じるとメモリを使い切る
+const MaxPeople = 100
+
+// Even は total を people 人で均等に分けた金額を返す
+// 0 番目が幹事 (立て替えた人) で、合計は total と一致するとは限らない
+func (r Rounding) Even(total, people int) []int {
+	amounts := make([]int, people)
+	unit := max(r.Unit, 1)
+
+	switch r.Policy {
+	case RoundOrganizer:
+		per := total / people / unit * unit
+		for i := 1; i < people; i++ {
+			amounts[i] = per
+		}
+		amounts[0] = total - per*(people-1)
+	case RoundFirst:
+		per, rest := total/people, total%people
+		for i := range amounts {
+			amounts[i] = per
+			if i < rest {
+				amounts[i]++
+			}
+		}
+	default:
+		per := (total + people*unit - 1) / (people * unit) * unit
+		for i := range amounts {
+			amounts[i] = per
+		}
+	}
+	return amounts
+}
@@ -0,0 +1,138 @@
+package split
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Share は 1 人分の負担の決め方
+// Fixed なら Amount 円を払い、そうでなければ残りを Weight の比で分ける
+type Share struct {
+	Name   string  `json:"name"`
+	Weight float64 `json:"weight,omitempty"`
+	Fixed  bool    `json:"fixed,omitempty"`
+	Amount int     `json:"amount,omitempty"`
+	// Unnamed は名前が書かれず "N人目" にしたとき true
+	Unnamed bool `json:"unnamed,omitempty"`
+}
+
+// Allocate は total を shares に配分した金額を返す
+// 端数は小数部分の大きい人から 1 円ずつ足すので、合計は必ず total になる
+func Allocate(total int, shares []Share) ([]int, error) {
+	amounts := make([]int, len(shares))
+
+	rest := total
+	var weight float64
+	for i, s := range shares {
+		if s.Fixed {
+			amounts[i] = s.Amount
+			rest -= s.Amount
+			continue
+		}
+		if !ValidWeight(s.Weight) {
+			return nil, fmt.Errorf("%s の重みが変じゃない？", s.Name)
+		}
+		weight += s.Weight
+	}
+	// 大きな重みを足すと Inf になることもある
+	if math.IsInf(weight, 0) {
+		return nil, errors.New("重みが大きすぎるよ")
+	}
+	if rest < 0 {
+		return nil, fmt.Errorf("金額指定の合計が %d 円を超えてるよ", total)
+	}
+	if rest == 0 {
+		return amounts, nil
+	}
+	if weight <= 0 {
+		return nil, errors.New("残りを払う人がいないよ")
+	}
+
+	type frac struct {
+		index int
+		part  float64
+	}
+	var fracs []frac
+	assigned := 0
+	for i, s := range shares {
+		if s.Fixed || s.Weight <= 0 {
+			continue
+		}
+		exact := float64(rest) * (s.Weight / weight)
+		floor := math.Floor(exact)
+		amounts[i] = int(floor)
+		assigned += int(floor)
+		fracs = append(fracs, frac{index: i, part: exact - floor})
+	}
+
+	sort.SliceStable(fracs, func(a, b int) bool { return fracs[a].part > fracs[b].part })
+	for n := 0; assigned < rest; n++ {
+		amounts[fracs[n%len(fracs)].index]++
+		assigned++
+	}
+
+	return amounts, nil
+}
+
+// ParseShares は 1 行 1 人の配分を読む
+//
+//	名前 2       … 重み 2
+//	名前 3000円  … 3000 円固定 (=3000 でもいい)
+//	0.5          … 名前なしで重み 0.5
+func ParseShares(text string) ([]Share, error) {
+	var shares []Share
+	for _, line := range strings.Split(text, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		name := strconv.Itoa(len(shares)+1) + "人目"
+		value := fields[len(fields)-1]
+		if len(fields) > 1 {
+			name = strings.Join(fields[:len(fields)-1], " ")
+		}
+
+		share := Share{Name: name, Unnamed: len(fields) == 1}
+		if v, ok := cutFixed(value); ok {
+			amount, err := strconv.Atoi(v)
+			if err != nil || amount < 0 {
+				return nil, fmt.Errorf("%s の金額が変じゃない？", name)
+			}
+			share.Fixed = true
+			share.Amount = amount
+		} else {
+			w, err := strconv.ParseFloat(value, 64)
+			if err != nil || !ValidWeight(w) {
+				return nil, fmt.Errorf("%s の重みが変じゃない？", name)
+			}
+			share.Weight = w
+		}
+		shares = append(shares, share)
+	}
+
+	if len(shares) == 0 {
+		return nil, errors.New("誰がどれだけ払うか教えてよね")
+	}
+	return shares, nil
+}
+
+// ValidWeight は w が 0 以上の有限の数かどうかを返す
+// Inf や NaN を通すと Allocate の端数配りが終わらなくなる
+func ValidWeight(w float64) bool {
+	return w >= 0 && !math.IsInf(w, 0) && !math.IsNaN(w)
+}
+
+func cutFixed(value string) (string, bool) {
+	if v, ok := strings.CutPrefix(value, "="); ok {
+		return v, true
+	}
+	if v, ok := strings.CutSuffix(value, "円"); ok {
+		return v, true
+	}
+	return value, false
+}
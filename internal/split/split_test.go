@@ -0,0 +1,189 @@
+package split
+
+import (
+	"math"
+	"testing"
+)
+
+func TestAllocate(t *testing.T) {
+	tests := []struct {
+		name   string
+		total  int
+		shares []Share
+		want   []int
+	}{
+		{
+			name:   "均等",
+			total:  10000,
+			shares: []Share{{Weight: 1}, {Weight: 1}, {Weight: 1}},
+			want:   []int{3334, 3333, 3333},
+		},
+		{
+			name:   "子どもは半額",
+			total:  5000,
+			shares: []Share{{Weight: 1}, {Weight: 1}, {Weight: 0.5}},
+			want:   []int{2000, 2000, 1000},
+		},
+		{
+			name:   "金額指定と重み",
+			total:  12000,
+			shares: []Share{{Fixed: true, Amount: 3000}, {Weight: 2}, {Weight: 1}},
+			want:   []int{3000, 6000, 3000},
+		},
+		{
+			name:   "重み 0 の人は払わない",
+			total:  1001,
+			shares: []Share{{Weight: 1}, {Weight: 0}, {Weight: 1}},
+			want:   []int{501, 0, 500},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Allocate(tt.total, tt.shares)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sum := 0
+			for i := range got {
+				sum += got[i]
+				if got[i] != tt.want[i] {
+					t.Errorf("Allocate() = %v, want %v", got, tt.want)
+					break
+				}
+			}
+			if sum != tt.total {
+				t.Errorf("sum = %d, want %d", sum, tt.total)
+			}
+		})
+	}
+
+	if _, err := Allocate(1000, []Share{{Fixed: true, Amount: 1200}, {Weight: 1}}); err == nil {
+		t.Error("fixed amounts over total should fail")
+	}
+	for _, w := range []float64{math.Inf(1), math.NaN(), math.MaxFloat64} {
+		if _, err := Allocate(1000, []Share{{Weight: w}, {Weight: w}}); err == nil {
+			t.Errorf("Allocate() with weight %v should fail", w)
+		}
+	}
+}
+
+func TestParseShares(t *testing.T) {
+	got, err := ParseShares("たろう 2\nはなこ =1500\n\n0.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Share{
+		{Name: "たろう", Weight: 2},
+		{Name: "はなこ", Fixed: true, Amount: 1500},
+		{Name: "3人目", Weight: 0.5, Unnamed: true},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("ParseShares() = %+v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("ParseShares()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	for _, in := range []string{"たろう Inf", "たろう +Inf", "たろう infinity", "たろう NaN", "たろう -1"} {
+		if _, err := ParseShares(in); err == nil {
+			t.Errorf("ParseShares(%q) should fail", in)
+		}
+	}
+}
+
+func TestAllocateItems(t *testing.T) {
+	items := []Item{
+		{Name: "ビール", Amount: 500, Tax: 0.1},
+		{Name: "ピザ", Amount: 1200, Tax: 0.1},
+		{Name: "サラダ", Amount: 800, Tax: 0.08},
+		{Name: "お通し", Amount: 600, Tax: 0.1},
+	}
+	claims := []Claim{
+		{UserID: "a", Items: []int{0, 1}},
+		{UserID: "b", Items: []int{1, 2}},
+	}
+
+	got, err := AllocateItems(items, claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := ItemsTotal(items)
+	if got[0]+got[1] != total {
+		t.Errorf("sum = %d, want %d", got[0]+got[1], total)
+	}
+	// a: 550 + 660 = 1210, b: 660 + 864 = 1524 にお通し 660 を比例配分
+	if got[0] != 1502 || got[1] != 1892 {
+		t.Errorf("AllocateItems() = %v", got)
+	}
+
+	if _, err := AllocateItems(items, nil); err == nil {
+		t.Error("no claims should fail")
+	}
+}
+
+func TestRoundingEven(t *testing.T) {
+	tests := []struct {
+		rounding Rounding
+		want     []int
+	}{
+		{Rounding{Policy: RoundUp, Unit: 1}, []int{3334, 3334, 3334}},
+		{Rounding{Policy: RoundUp, Unit: 100}, []int{3400, 3400, 3400}},
+		{Rounding{Policy: RoundOrganizer, Unit: 1}, []int{3335, 3333, 3333}},
+		{Rounding{Policy: RoundOrganizer, Unit: 100}, []int{3401, 3300, 3300}},
+		{Rounding{Policy: RoundFirst, Unit: 1}, []int{3334, 3334, 3333}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rounding.Label(), func(t *testing.T) {
+			got := tt.rounding.Even(10001, 3)
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Fatalf("Even() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseGroupMembers(t *testing.T) {
+	got, err := ParseGroupMembers([]string{"<@1>", "<@2>", "2", "こども", "0.5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Share{{Name: "<@1>", Weight: 1}, {Name: "<@2>", Weight: 2}, {Name: "こども", Weight: 0.5}}
+	if len(got) != len(want) {
+		t.Fatalf("ParseGroupMembers() = %+v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("ParseGroupMembers()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	if _, err := ParseGroupMembers([]string{"2"}); err == nil {
+		t.Error("ParseGroupMembers() with weight only should fail")
+	}
+	for _, w := range []string{"Inf", "infinity", "NaN", "0"} {
+		if _, err := ParseGroupMembers([]string{"<@1>", w}); err == nil {
+			t.Errorf("ParseGroupMembers() with weight %s should fail", w)
+		}
+	}
+}
+
+func TestAdjustment(t *testing.T) {
+	a, err := ParseAdjustment("-1000 +10% 税10%")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := a.Apply(12000)
+	want := Adjusted{Subtotal: 12000, Discount: 1000, Surcharge: 1100, Tax: 1210, Total: 13310}
+	if got != want {
+		t.Fatalf("Apply() = %+v, want %+v", got, want)
+	}
+
+	if _, err := ParseAdjustment("+10"); err == nil {
+		t.Error("ParseAdjustment() without % should fail")
+	}
+}
@@ -0,0 +1,17 @@
+package split
+
+// Payment は誰かが立て替えた 1 件
+type Payment struct {
+	UserID string `json:"user_id"`
+	Amount int    `json:"amount"`
+	Note   string `json:"note"`
+}
+
+// EvenShares は n 人で均等に分ける配分
+func EvenShares(n int) []Share {
+	shares := make([]Share, n)
+	for i := range shares {
+		shares[i].Weight = 1
+	}
+	return shares
+}
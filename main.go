@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
@@ -16,6 +17,7 @@ import (
 
 	"pyonchi/gemini"
 	"pyonchi/handlers"
+	"pyonchi/internal/convo"
 	"pyonchi/notion"
 )
 
@@ -33,7 +35,7 @@ func main() {
 		log.Println("GEMINI_API_KEY を設定してください")
 		return
 	}
-	geminiClient := gemini.NewClient(geminiToken)
+	handlers.SetGeminiClient(gemini.NewClient(geminiToken))
 
 	discordToken := os.Getenv("DISCORD_TOKEN")
 	if discordToken == "" {
@@ -61,8 +63,68 @@ func main() {
 
 	// Notion クライアントを作成してハンドラにセット
 	notionClient := notion.NewClient(notionKey, notionDB)
+	// 家計簿データベースのプロパティ名と型 (未設定ならもともとのデータベースの名前)
+	if schemaPath := os.Getenv("NOTION_SCHEMA_PATH"); schemaPath != "" {
+		schema, err := notion.LoadSchema(schemaPath)
+		if err != nil {
+			log.Fatalf("notion.LoadSchema error: %v", err)
+			return
+		}
+		notionClient.SetSchema(schema)
+	}
 	handlers.SetNotionClient(notionClient)
 
+	// 列の名前や選択肢が Notion 側で変わっていないか、受け付けを始める前に確かめる
+	// カテゴリと財布の選択肢もここで取ってくる (取れなければ組み込みのものを使う)
+	if report, err := handlers.CheckNotionSchema(); err != nil {
+		log.Printf("Notion schema check error: %v", err)
+	} else if !report.OK() {
+		log.Printf("⚠️ %s", report)
+	} else {
+		log.Println(report)
+	}
+
+	// カテゴリと財布の選択肢を取り直す間隔
+	optionsInterval := time.Hour
+	if v := os.Getenv("NOTION_OPTIONS_REFRESH_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			log.Fatalf("NOTION_OPTIONS_REFRESH_INTERVAL は 30m のように書いてください: %q", v)
+			return
+		}
+		optionsInterval = d
+	}
+
+	// 会話ステートの保存先 (未設定ならメモリに保持)
+	if storePath := os.Getenv("CONVO_STORE_PATH"); storePath != "" {
+		convoStore, err := convo.OpenFileStore(storePath)
+		if err != nil {
+			log.Fatalf("convo.OpenFileStore error: %v", err)
+			return
+		}
+		handlers.SetConvoStore(convoStore)
+	}
+
+	// いつものメンバーなどの設定の保存先 (未設定ならメモリに保持)
+	if configPath := os.Getenv("CONFIG_STORE_PATH"); configPath != "" {
+		configStore, err := convo.OpenFileStore(configPath)
+		if err != nil {
+			log.Fatalf("convo.OpenFileStore error: %v", err)
+			return
+		}
+		handlers.SetConfigStore(configStore)
+	}
+
+	// 貸し借りの台帳の保存先 (未設定ならメモリに保持)
+	if ledgerPath := os.Getenv("LEDGER_STORE_PATH"); ledgerPath != "" {
+		ledgerStore, err := convo.OpenFileStore(ledgerPath)
+		if err != nil {
+			log.Fatalf("convo.OpenFileStore error: %v", err)
+			return
+		}
+		handlers.SetLedgerStore(ledgerStore)
+	}
+
 	// Discord Bot
 	dg, err := discordgo.New("Bot " + discordToken)
 	if err != nil {
@@ -93,6 +155,36 @@ func main() {
 			return
 		}
 
+		// 精算トリガー
+		if isSettleTrigger(content) {
+			handlers.SettleHandle(s, m)
+			return
+		}
+
+		// いつものメンバーの登録トリガー
+		if isGroupTrigger(content) {
+			handlers.GroupHandle(s, m)
+			return
+		}
+
+		// グループ割り勘への立て替えトリガー
+		if isGroupPaidTrigger(content) {
+			handlers.GroupPaidHandle(s, m)
+			return
+		}
+
+		// 為替レートの登録トリガー
+		if isRateTrigger(content) {
+			handlers.RateHandle(s, m)
+			return
+		}
+
+		// カテゴリ・財布の選択肢の更新トリガー
+		if isOptionsRefreshTrigger(content) {
+			handlers.OptionsRefreshHandle(s, m)
+			return
+		}
+
 		// 家計簿記録トリガー
 		if isExpenseManualTrigger(content) {
 			handlers.ExpenseManualHandleOngoing(s, m)
@@ -101,17 +193,15 @@ func main() {
 
 		// レシート画像トリガー
 		if isExpenseReceiptTrigger(m) {
-			handlers.ExpenseReceiptHandleOngoing(s, m, geminiClient)
+			handlers.ExpenseReceiptHandleOngoing(s, m)
 			return
 		}
 
 		// 進行中の会話があれば各ハンドラが処理する
-		handlers.RouteOngoingConversations(s, m, geminiClient)
+		handlers.RouteOngoingConversations(s, m)
 	})
 
-	dg.AddHandler(handlers.WalletInteractionHandler)
-	dg.AddHandler(handlers.CategoryInteractionHandler)
-	dg.AddHandler(handlers.ReceiptWalletInteractionHandler)
+	dg.AddHandler(handlers.InteractionHandler)
 
 	if err := dg.Open(); err != nil {
 		log.Fatalf("Discord Open error: %v", err)
@@ -121,6 +211,19 @@ func main() {
 
 	log.Println("Discord bot connected")
 
+	// スラッシュコマンドを登録 (DISCORD_GUILD_ID が未設定ならグローバル)
+	// 前回登録したものはまるごと置き換わるので、終了時には消さない (Cloud Run では別のインスタンスが使っている)
+	guildID := os.Getenv("DISCORD_GUILD_ID")
+	if _, err := dg.ApplicationCommandBulkOverwrite(dg.State.User.ID, guildID, handlers.ApplicationCommands()); err != nil {
+		log.Printf("ApplicationCommandBulkOverwrite error: %v", err)
+	}
+
+	// 放置された会話を時間切れにする
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go handlers.RunConversationSweeper(ctx, dg)
+	go handlers.RunExpenseOptionsRefresher(ctx, optionsInterval)
+
 	// HTTP サーバ（Cloud Run 用）
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprintf(w, "OK")
@@ -149,21 +252,54 @@ func main() {
 	<-stop
 
 	log.Println("Shutting down")
+	cancel()
+
 	dg.Close()
 	time.Sleep(1 * time.Second)
 }
 
+// 割り勘の引数 (金額・人数・メンション) は後ろに付いていてもいい
 func isSplitTrigger(content string) bool {
-	c := normalize(content)
-	return c == "ぴょんちー　割り勘" || c == "ぴょんちー 割り勘" || c == "ぴょんちー割り勘"
+	name, _, ok := handlers.ParseCommand(normalize(content))
+	return ok && name == handlers.CommandSplit
+}
+
+func isSettleTrigger(content string) bool {
+	name, args, ok := handlers.ParseCommand(normalize(content))
+	return ok && name == handlers.CommandSettle && len(args) == 0
 }
 
+func isGroupTrigger(content string) bool {
+	name, _, ok := handlers.ParseCommand(normalize(content))
+	return ok && (name == handlers.CommandGroup || name == handlers.CommandGroupDelete)
+}
+
+func isGroupPaidTrigger(content string) bool {
+	name, _, ok := handlers.ParseCommand(normalize(content))
+	return ok && name == handlers.CommandGroupPaid
+}
+
+func isRateTrigger(content string) bool {
+	name, _, ok := handlers.ParseCommand(normalize(content))
+	return ok && name == handlers.CommandRate
+}
+
+func isOptionsRefreshTrigger(content string) bool {
+	name, _, ok := handlers.ParseCommand(normalize(content))
+	return ok && name == handlers.CommandOptionsRefresh
+}
+
+// 家計簿の引数 (タイトル・金額・カテゴリ・財布) は後ろに付いていてもいい
 func isExpenseManualTrigger(content string) bool {
-	c := normalize(content)
-	return c == "ぴょんちー 家計簿つけて" || c == "ぴょんちー家計簿つけて" || c == "ぴょんちー　家計簿つけて"
+	name, _, ok := handlers.ParseCommand(normalize(content))
+	return ok && (name == handlers.CommandExpense || name == handlers.CommandExpenseManual)
 }
 
 func isExpenseReceiptTrigger(m *discordgo.MessageCreate) bool {
+	// 割り勘中の画像はアイテムごとの割り勘に使う
+	if handlers.IsInSplitConversation(convo.Key(m.ChannelID, m.Author.ID)) {
+		return false
+	}
 	// メッセージに画像添付があるか
 	return !(len(m.Attachments) == 0)
 }
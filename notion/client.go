@@ -4,115 +4,89 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"time"
 )
 
-type Client struct {
-	apiKey string
-	dbID   string
-	http   *http.Client
-}
-
-type PageProperty struct {
-	Title *[]struct {
-		Text struct {
-			Content string `json:"content"`
-		} `json:"text"`
-	} `json:"title,omitempty"`
+// Notion API の URL
+const defaultBaseURL = "https://api.notion.com/v1"
 
-	Number *int `json:"number,omitempty"`
-
-	Select *struct {
-		Name string `json:"name"`
-	} `json:"select,omitempty"`
-
-	Date *struct {
-		Start string `json:"start"`
-	} `json:"date,omitempty"`
+type Client struct {
+	apiKey  string
+	dbID    string
+	baseURL string
+	schema  Schema
+	http    *http.Client
 }
 
 type CreatePageRequest struct {
 	Parent struct {
 		DatabaseID string `json:"database_id"`
 	} `json:"parent"`
-	Properties map[string]PageProperty `json:"properties"`
+	Properties Properties `json:"properties"`
 }
 
 type QueryResponse struct {
-	Results []struct {
-		Properties map[string]struct {
-			Type    string `json:"type"`
-			Number  *int   `json:"number,omitempty"`
-			Formula *struct {
-				Type   string `json:"type"`
-				Number int    `json:"number"`
-			} `json:"formula,omitempty"`
-			Title *[]struct {
-				Text struct {
-					Content string `json:"content"`
-				} `json:"text"`
-			} `json:"title,omitempty"`
-			Select *struct {
-				Name string `json:"name"`
-			} `json:"select,omitempty"`
-			Date *struct {
-				Start string `json:"start"`
-			} `json:"date,omitempty"`
-		} `json:"properties"`
-	} `json:"results"`
+	Results    []Page  `json:"results"`
+	HasMore    bool    `json:"has_more"`
+	NextCursor *string `json:"next_cursor"`
 }
 
 func NewClient(apiKey, dbID string) *Client {
 	return &Client{
-		apiKey: apiKey,
-		dbID:   dbID,
-		http:   &http.Client{Timeout: 10 * time.Second},
+		apiKey:  apiKey,
+		dbID:    dbID,
+		baseURL: defaultBaseURL,
+		schema:  DefaultSchema(),
+		http:    &http.Client{Timeout: 10 * time.Second},
 	}
 }
 
-func (c *Client) CreateExpenseRecord(title string, category string, amount int, people int, wallet string, date time.Time) error {
+// SetSchema は家計簿データベースのプロパティの対応を差し替える
+func (c *Client) SetSchema(s Schema) {
+	c.schema = s
+}
+
+// CreateExpenseRecord は家計簿を 1 件記録する
+// 外貨で払ったときは currency に通貨コード、originalAmount に一人あたりの現地の金額を渡す
+// 外貨の列が Schema に設定されていなければ、円に換算した金額だけを記録する
+// 円なら currency は "" か "JPY" にする
+func (c *Client) CreateExpenseRecord(title string, category string, amount int, people int, wallet string, date time.Time, currency string, originalAmount float64) error {
+	e := Expense{
+		Title:          title,
+		Category:       category,
+		Amount:         amount,
+		People:         people,
+		Wallet:         wallet,
+		Date:           date,
+		Currency:       currency,
+		OriginalAmount: originalAmount,
+	}
+	return c.CreatePage(c.schema.ExpenseProperties(e))
+}
+
+// CreatePage はデータベースに props のページを 1 つ作る
+func (c *Client) CreatePage(props Properties) error {
 	reqBody := CreatePageRequest{}
 	reqBody.Parent.DatabaseID = c.dbID
-	reqBody.Properties = map[string]PageProperty{
-		"費目": {
-			Title: &[]struct {
-				Text struct {
-					Content string `json:"content"`
-				} `json:"text"`
-			}{
-				{
-					Text: struct {
-						Content string `json:"content"`
-					}{Content: title},
-				},
-			},
-		},
-		"一人あたりの支払額": {
-			Number: &amount,
-		},
-		"支払人数": {
-			Number: &people,
-		},
-		"カテゴリ": {
-			Select: &struct {
-				Name string `json:"name"`
-			}{Name: category},
-		},
-		"財布": {
-			Select: &struct {
-				Name string `json:"name"`
-			}{Name: wallet},
-		},
-		"支払日時": {
-			Date: &struct {
-				Start string `json:"start"`
-			}{Start: date.Format("2006-01-02")},
-		},
+	reqBody.Properties = props
+
+	return c.do("POST", "/pages", reqBody, nil)
+}
+
+// do は Notion API にリクエストを送り、レスポンスを out に読み込む。out が nil なら読まない
+func (c *Client) do(method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		body = bytes.NewReader(b)
 	}
 
-	b, _ := json.Marshal(reqBody)
-	request, err := http.NewRequest("POST", "https://api.notion.com/v1/pages", bytes.NewBuffer(b))
+	request, err := http.NewRequest(method, c.baseURL+path, body)
 	if err != nil {
 		return err
 	}
@@ -131,94 +105,43 @@ func (c *Client) CreateExpenseRecord(title string, category string, amount int,
 		body := new(bytes.Buffer)
 		body.ReadFrom(resp.Body)
 		fmt.Println(body.String())
+		// Notion はエラーの理由を message に入れて返す
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(body.Bytes(), &apiErr) == nil && apiErr.Message != "" {
+			return fmt.Errorf("notion API error: %d: %s", resp.StatusCode, apiErr.Message)
+		}
 		return fmt.Errorf("notion API error: %d", resp.StatusCode)
 	}
 
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode notion response: %w", err)
+	}
 	return nil
 }
 
+// GetMonthlyExpenseTotal は今月の category の総支払額の合計を返す
 func (c *Client) GetMonthlyExpenseTotal(category string) (int, error) {
 	now := time.Now()
 	startOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
 
-	// Notion API のフィルター構造
-	type queryRequest struct {
-		Filter struct {
-			And []interface{} `json:"and"`
-		} `json:"filter"`
-	}
-
-	type dateFilter struct {
-		Property string `json:"property"`
-		Date     struct {
-			After string `json:"after"`
-		} `json:"date"`
-	}
-
-	type selectFilter struct {
-		Property string `json:"property"`
-		Select   struct {
-			Equals string `json:"equals"`
-		} `json:"select"`
-	}
+	it := c.Query(Query{
+		Filter: And(
+			DateOnOrAfter(c.schema.Date.Name, startOfMonth),
+			c.schema.Category.Equals(category),
+		),
+	})
 
-	q := queryRequest{}
-	q.Filter.And = []interface{}{
-		dateFilter{
-			Property: "支払日時",
-			Date: struct {
-				After string `json:"after"`
-			}{
-				After: startOfMonth.Format("2006-01-02"),
-			},
-		},
-		selectFilter{
-			Property: "カテゴリ",
-			Select: struct {
-				Equals string `json:"equals"`
-			}{
-				Equals: category,
-			},
-		},
-	}
-
-	b, _ := json.Marshal(q)
-	request, err := http.NewRequest("POST", fmt.Sprintf("https://api.notion.com/v1/databases/%s/query", c.dbID), bytes.NewBuffer(b))
-	if err != nil {
-		return 0, err
-	}
-
-	request.Header.Set("Authorization", "Bearer "+c.apiKey)
-	request.Header.Set("Notion-Version", "2022-06-28")
-	request.Header.Set("Content-Type", "application/json")
-
-	resp, err := c.http.Do(request)
-	if err != nil {
-		return 0, err
+	var sum int
+	for it.Next() {
+		sum += c.schema.ExpenseFromPage(it.Page()).Total
 	}
-	defer resp.Body.Close()
-
-	body := new(bytes.Buffer)
-	body.ReadFrom(resp.Body)
-
-	fmt.Println("Response body:", body.String())
-
-	var result QueryResponse
-	if err := json.Unmarshal(body.Bytes(), &result); err != nil {
-		fmt.Println("Error decoding Notion response:", err)
+	if err := it.Err(); err != nil {
 		return 0, err
 	}
-
-	var sum int
-	for _, r := range result.Results {
-		fmt.Println("Processing record:", r)
-		if amt, ok := r.Properties["総支払額"]; ok {
-			if amt.Formula != nil {
-				fmt.Println("Found amount:", amt.Formula.Number)
-				sum += amt.Formula.Number
-			}
-		}
-	}
-
 	return sum, nil
 }
@@ -0,0 +1,126 @@
+package notion
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Database はデータベースの定義
+type Database struct {
+	ID         string                      `json:"id"`
+	Title      []RichText                  `json:"title"`
+	Properties map[string]DatabaseProperty `json:"properties"`
+}
+
+// DatabaseProperty はデータベースの 1 列の定義
+type DatabaseProperty struct {
+	ID          string         `json:"id"`
+	Name        string         `json:"name"`
+	Type        string         `json:"type"`
+	Select      *SelectOptions `json:"select,omitempty"`
+	MultiSelect *SelectOptions `json:"multi_select,omitempty"`
+}
+
+// SelectOptions はセレクト・マルチセレクトの列の選択肢
+type SelectOptions struct {
+	Options []SelectOption `json:"options"`
+}
+
+// OptionNames は列の選択肢の名前を返す。セレクトでなければ nil
+func (p DatabaseProperty) OptionNames() []string {
+	opts := p.Select
+	if opts == nil {
+		opts = p.MultiSelect
+	}
+	if opts == nil {
+		return nil
+	}
+	names := make([]string, 0, len(opts.Options))
+	for _, o := range opts.Options {
+		names = append(names, o.Name)
+	}
+	return names
+}
+
+// RetrieveDatabase は家計簿データベースの定義を取ってくる
+func (c *Client) RetrieveDatabase() (*Database, error) {
+	var db Database
+	if err := c.do("GET", "/databases/"+c.dbID, nil, &db); err != nil {
+		return nil, fmt.Errorf("failed to retrieve database: %w", err)
+	}
+	return &db, nil
+}
+
+// SchemaReport はデータベースの定義と Schema を突き合わせた結果
+type SchemaReport struct {
+	Database string
+	Problems []string
+}
+
+// OK は食い違いがなかったかどうかを返す
+func (r SchemaReport) OK() bool {
+	return len(r.Problems) == 0
+}
+
+func (r SchemaReport) String() string {
+	if r.OK() {
+		return fmt.Sprintf("Notion データベース「%s」の列はすべて揃ってる", r.Database)
+	}
+	return fmt.Sprintf("Notion データベース「%s」に %d 件の食い違いがある\n- %s",
+		r.Database, len(r.Problems), strings.Join(r.Problems, "\n- "))
+}
+
+// CheckSchema は RetrieveDatabase で取ってきた db と、対応づけた列とその型、
+// カテゴリ・財布の選択肢が揃っているかを確かめる
+func (c *Client) CheckSchema(db *Database, categories, wallets []string) SchemaReport {
+	return c.schema.Check(db, categories, wallets)
+}
+
+// Check は db の定義と s を突き合わせる
+func (s Schema) Check(db *Database, categories, wallets []string) SchemaReport {
+	r := SchemaReport{Database: Property{Title: db.Title}.Text()}
+	if r.Database == "" {
+		r.Database = db.ID
+	}
+
+	for _, f := range s.fields() {
+		if f.field.Name == "" {
+			continue
+		}
+		p, ok := db.Properties[f.field.Name]
+		if !ok {
+			r.Problems = append(r.Problems, fmt.Sprintf("%s: 列「%s」が見つからない", f.key, f.field.Name))
+			continue
+		}
+		if p.Type != f.field.Type {
+			r.Problems = append(r.Problems, fmt.Sprintf("%s: 列「%s」の型が %s になってる (%s のはず)", f.key, f.field.Name, p.Type, f.field.Type))
+		}
+	}
+
+	r.checkOptions(db, s.Category, categories)
+	r.checkOptions(db, s.Wallet, wallets)
+	return r
+}
+
+// checkOptions は f がセレクトの列なら want の選択肢がすべてあるかを確かめる
+func (r *SchemaReport) checkOptions(db *Database, f Field, want []string) {
+	p, ok := db.Properties[f.Name]
+	if f.Name == "" || !ok || (p.Type != TypeSelect && p.Type != TypeMultiSelect) {
+		return
+	}
+	have := p.OptionNames()
+	if len(have) == 0 {
+		r.Problems = append(r.Problems, fmt.Sprintf("列「%s」に選択肢が 1 つもない", f.Name))
+	}
+	for _, w := range want {
+		if !slices.Contains(have, w) {
+			r.Problems = append(r.Problems, fmt.Sprintf("列「%s」に選択肢「%s」がない", f.Name, w))
+		}
+	}
+}
+
+// ExpenseOptions は RetrieveDatabase で取ってきた db の、カテゴリと財布の列の選択肢を返す
+func (c *Client) ExpenseOptions(db *Database) (categories, wallets []string) {
+	return db.Properties[c.schema.Category.Name].OptionNames(), db.Properties[c.schema.Wallet.Name].OptionNames()
+}
@@ -0,0 +1,48 @@
+package notion
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckSchema(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" || r.URL.Path != "/databases/db" {
+			t.Errorf("%s %s", r.Method, r.URL.Path)
+		}
+		fmt.Fprint(w, `{"id":"db","title":[{"plain_text":"家計簿"}],"properties":{
+			"費目":{"name":"費目","type":"title"},
+			"一人あたりの支払額":{"name":"一人あたりの支払額","type":"number"},
+			"支払人数":{"name":"支払人数","type":"rich_text"},
+			"カテゴリ":{"name":"カテゴリ","type":"select","select":{"options":[{"name":"旅行"}]}},
+			"財布":{"name":"財布","type":"select","select":{"options":[{"name":"おひ財布"}]}},
+			"支払日時":{"name":"支払日時","type":"date"},
+			"総支払額":{"name":"総支払額","type":"formula"},
+			"現地通貨":{"name":"現地通貨","type":"select","select":{"options":[]}}
+		}}`)
+	}))
+	defer srv.Close()
+
+	c := NewClient("key", "db")
+	c.baseURL = srv.URL
+
+	db, err := c.RetrieveDatabase()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := c.CheckSchema(db, []string{"旅行"}, []string{"おひ財布", "B/43"})
+	want := []string{
+		"people: 列「支払人数」の型が rich_text になってる (number のはず)",
+		"列「財布」に選択肢「B/43」がない",
+	}
+	if r.Database != "家計簿" || len(r.Problems) != len(want) {
+		t.Fatalf("CheckSchema() = %+v", r)
+	}
+	for i := range want {
+		if r.Problems[i] != want[i] {
+			t.Errorf("Problems[%d] = %q, want %q", i, r.Problems[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package notion
+
+import "time"
+
+// Expense は家計簿データベースの 1 行
+type Expense struct {
+	Title    string
+	Category string
+	Amount   int // 一人あたりの支払額 (円)
+	People   int
+	Wallet   string
+	Date     time.Time
+
+	// 外貨で払ったときの通貨と一人あたりの現地の金額。円なら Currency は "" か "JPY"
+	Currency       string
+	OriginalAmount float64
+
+	// Total は総支払額 (数式)。読み取りのときだけ入る
+	Total int
+}
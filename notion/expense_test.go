@@ -0,0 +1,72 @@
+package notion
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestExpenseRoundTrip(t *testing.T) {
+	want := Expense{
+		Title:          "ランチ",
+		Category:       "旅行",
+		Amount:         1878,
+		People:         2,
+		Wallet:         "ぽよ財布",
+		Date:           time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+		Currency:       "USD",
+		OriginalAmount: 12.5,
+	}
+
+	s := DefaultSchema()
+	s.Currency = Field{Name: "現地通貨", Type: TypeSelect}
+	s.Original = Field{Name: "現地金額", Type: TypeNumber}
+
+	b, err := json.Marshal(s.ExpenseProperties(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var page Page
+	if err := json.Unmarshal(b, &page.Properties); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := s.ExpenseFromPage(page); got != want {
+		t.Fatalf("ExpenseFromPage() = %+v, want %+v", got, want)
+	}
+}
+
+func TestPropertyAccessors(t *testing.T) {
+	var p Property
+	err := json.Unmarshal([]byte(`{"type":"formula","formula":{"type":"number","number":3600}}`), &p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := p.NumberValue(); !ok || v != 3600 {
+		t.Fatalf("NumberValue() = %v, %v", v, ok)
+	}
+
+	p = MultiSelectProperty("a", "b")
+	if names := p.MultiSelectNames(); len(names) != 2 || names[1] != "b" {
+		t.Fatalf("MultiSelectNames() = %v", names)
+	}
+}
+
+func TestCustomSchema(t *testing.T) {
+	s := DefaultSchema()
+	s.Title = Field{Name: "Name", Type: TypeTitle}
+	s.Wallet = Field{Name: "Wallet", Type: TypeRichText}
+
+	props := s.ExpenseProperties(Expense{Title: "Lunch", Wallet: "Cash", Currency: "USD", OriginalAmount: 10})
+	if props["Name"].Text() != "Lunch" || props["Wallet"].Text() != "Cash" {
+		t.Fatalf("ExpenseProperties() = %+v", props)
+	}
+	if len(props) != 6 {
+		t.Fatalf("currency fields are not in the default schema and should not be written: %+v", props)
+	}
+
+	s.Amount.Type = TypeSelect
+	if err := s.validate(); err == nil {
+		t.Fatal("validate() should reject a select amount")
+	}
+}
@@ -0,0 +1,282 @@
+package notion
+
+import (
+	"strings"
+	"time"
+)
+
+// Notion の日付プロパティの書式
+const dateLayout = "2006-01-02"
+
+// Properties はページのプロパティ名ごとの値
+type Properties map[string]Property
+
+// Page はデータベースの 1 行
+type Page struct {
+	ID         string     `json:"id"`
+	Properties Properties `json:"properties"`
+}
+
+// Property はページのプロパティ 1 つ
+// Type に応じてどれか 1 つのフィールドに値が入る。書き込むときは Type は空でいい
+type Property struct {
+	ID   string `json:"id,omitempty"`
+	Type string `json:"type,omitempty"`
+
+	Title       []RichText     `json:"title,omitempty"`
+	RichText    []RichText     `json:"rich_text,omitempty"`
+	Number      *float64       `json:"number,omitempty"`
+	Select      *SelectOption  `json:"select,omitempty"`
+	MultiSelect []SelectOption `json:"multi_select,omitempty"`
+	Date        *DateValue     `json:"date,omitempty"`
+	Checkbox    *bool          `json:"checkbox,omitempty"`
+	Relation    []Relation     `json:"relation,omitempty"`
+	People      []User         `json:"people,omitempty"`
+	Files       []File         `json:"files,omitempty"`
+
+	// Formula と Rollup は読み取り専用
+	Formula *Formula `json:"formula,omitempty"`
+	Rollup  *Rollup  `json:"rollup,omitempty"`
+}
+
+// RichText はタイトルやテキストの 1 かたまり
+type RichText struct {
+	Type      string       `json:"type,omitempty"`
+	Text      *TextContent `json:"text,omitempty"`
+	PlainText string       `json:"plain_text,omitempty"`
+}
+
+type TextContent struct {
+	Content string `json:"content"`
+}
+
+// SelectOption はセレクト・マルチセレクトの選択肢
+type SelectOption struct {
+	ID    string `json:"id,omitempty"`
+	Name  string `json:"name"`
+	Color string `json:"color,omitempty"`
+}
+
+type DateValue struct {
+	Start string  `json:"start"`
+	End   *string `json:"end,omitempty"`
+}
+
+// Relation は関連先のページ
+type Relation struct {
+	ID string `json:"id"`
+}
+
+// User は Notion のユーザー
+type User struct {
+	Object string `json:"object,omitempty"`
+	ID     string `json:"id"`
+	Name   string `json:"name,omitempty"`
+}
+
+// File は外部 URL か Notion にアップロードされたファイル
+type File struct {
+	Name     string   `json:"name"`
+	Type     string   `json:"type,omitempty"`
+	External *FileURL `json:"external,omitempty"`
+	File     *FileURL `json:"file,omitempty"`
+}
+
+type FileURL struct {
+	URL string `json:"url"`
+}
+
+// Formula は数式プロパティの計算結果
+type Formula struct {
+	Type    string     `json:"type"`
+	Number  *float64   `json:"number,omitempty"`
+	String  *string    `json:"string,omitempty"`
+	Boolean *bool      `json:"boolean,omitempty"`
+	Date    *DateValue `json:"date,omitempty"`
+}
+
+// Rollup は集計プロパティの結果
+type Rollup struct {
+	Type   string     `json:"type"`
+	Number *float64   `json:"number,omitempty"`
+	Date   *DateValue `json:"date,omitempty"`
+	Array  []Property `json:"array,omitempty"`
+}
+
+// --- 書き込み用のプロパティを作る関数 ---
+
+func TitleProperty(text string) Property {
+	return Property{Title: richText(text)}
+}
+
+func RichTextProperty(text string) Property {
+	return Property{RichText: richText(text)}
+}
+
+func NumberProperty(n float64) Property {
+	return Property{Number: &n}
+}
+
+func SelectProperty(name string) Property {
+	return Property{Select: &SelectOption{Name: name}}
+}
+
+func MultiSelectProperty(names ...string) Property {
+	opts := make([]SelectOption, 0, len(names))
+	for _, n := range names {
+		opts = append(opts, SelectOption{Name: n})
+	}
+	return Property{MultiSelect: opts}
+}
+
+// DateProperty は日付だけのプロパティを作る
+func DateProperty(t time.Time) Property {
+	return Property{Date: &DateValue{Start: t.Format(dateLayout)}}
+}
+
+func CheckboxProperty(checked bool) Property {
+	return Property{Checkbox: &checked}
+}
+
+func RelationProperty(pageIDs ...string) Property {
+	rels := make([]Relation, 0, len(pageIDs))
+	for _, id := range pageIDs {
+		rels = append(rels, Relation{ID: id})
+	}
+	return Property{Relation: rels}
+}
+
+func PeopleProperty(userIDs ...string) Property {
+	users := make([]User, 0, len(userIDs))
+	for _, id := range userIDs {
+		users = append(users, User{Object: "user", ID: id})
+	}
+	return Property{People: users}
+}
+
+// FilesProperty は外部 URL のファイルを並べる
+func FilesProperty(urls ...string) Property {
+	files := make([]File, 0, len(urls))
+	for _, u := range urls {
+		files = append(files, File{Name: u, Type: "external", External: &FileURL{URL: u}})
+	}
+	return Property{Files: files}
+}
+
+func richText(text string) []RichText {
+	return []RichText{{Type: "text", Text: &TextContent{Content: text}}}
+}
+
+// --- 読み取り用の関数 ---
+
+// Text はタイトルやテキストをつなげた文字列を返す
+func (p Property) Text() string {
+	parts := p.Title
+	if len(parts) == 0 {
+		parts = p.RichText
+	}
+	var b strings.Builder
+	for _, rt := range parts {
+		switch {
+		case rt.PlainText != "":
+			b.WriteString(rt.PlainText)
+		case rt.Text != nil:
+			b.WriteString(rt.Text.Content)
+		}
+	}
+	if p.Formula != nil && p.Formula.String != nil {
+		b.WriteString(*p.Formula.String)
+	}
+	return b.String()
+}
+
+// NumberValue は数値を返す。数式や集計の数値も読む
+func (p Property) NumberValue() (float64, bool) {
+	switch {
+	case p.Number != nil:
+		return *p.Number, true
+	case p.Formula != nil && p.Formula.Number != nil:
+		return *p.Formula.Number, true
+	case p.Rollup != nil && p.Rollup.Number != nil:
+		return *p.Rollup.Number, true
+	}
+	return 0, false
+}
+
+// SelectName はセレクトで選ばれている名前を返す
+func (p Property) SelectName() string {
+	if p.Select == nil {
+		return ""
+	}
+	return p.Select.Name
+}
+
+// MultiSelectNames はマルチセレクトで選ばれている名前を返す
+func (p Property) MultiSelectNames() []string {
+	var names []string
+	for _, o := range p.MultiSelect {
+		names = append(names, o.Name)
+	}
+	return names
+}
+
+// DateValue は日付の開始日を返す。数式や集計の日付も読む
+func (p Property) DateValue() (time.Time, bool) {
+	d := p.Date
+	switch {
+	case d != nil:
+	case p.Formula != nil && p.Formula.Date != nil:
+		d = p.Formula.Date
+	case p.Rollup != nil && p.Rollup.Date != nil:
+		d = p.Rollup.Date
+	default:
+		return time.Time{}, false
+	}
+	// 時刻つきの日付もある
+	for _, layout := range []string{dateLayout, time.RFC3339} {
+		if t, err := time.Parse(layout, d.Start); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// CheckboxValue はチェックされているかを返す
+func (p Property) CheckboxValue() bool {
+	if p.Checkbox != nil {
+		return *p.Checkbox
+	}
+	return p.Formula != nil && p.Formula.Boolean != nil && *p.Formula.Boolean
+}
+
+// RelationIDs は関連先のページ ID を返す
+func (p Property) RelationIDs() []string {
+	var ids []string
+	for _, r := range p.Relation {
+		ids = append(ids, r.ID)
+	}
+	return ids
+}
+
+// PeopleIDs はユーザー ID を返す
+func (p Property) PeopleIDs() []string {
+	var ids []string
+	for _, u := range p.People {
+		ids = append(ids, u.ID)
+	}
+	return ids
+}
+
+// FileURLs はファイルの URL を返す
+func (p Property) FileURLs() []string {
+	var urls []string
+	for _, f := range p.Files {
+		switch {
+		case f.External != nil:
+			urls = append(urls, f.External.URL)
+		case f.File != nil:
+			urls = append(urls, f.File.URL)
+		}
+	}
+	return urls
+}
@@ -0,0 +1,182 @@
+package notion
+
+import (
+	"time"
+)
+
+// 並び順
+const (
+	Ascending  = "ascending"
+	Descending = "descending"
+)
+
+// Filter はデータベースを絞り込む条件
+// Property とどれか 1 つの条件を入れるか、And / Or で組み合わせる
+type Filter struct {
+	Property string `json:"property,omitempty"`
+
+	Title       *TextFilter     `json:"title,omitempty"`
+	RichText    *TextFilter     `json:"rich_text,omitempty"`
+	Number      *NumberFilter   `json:"number,omitempty"`
+	Select      *SelectFilter   `json:"select,omitempty"`
+	MultiSelect *SelectFilter   `json:"multi_select,omitempty"`
+	Date        *DateFilter     `json:"date,omitempty"`
+	Checkbox    *CheckboxFilter `json:"checkbox,omitempty"`
+
+	And []Filter `json:"and,omitempty"`
+	Or  []Filter `json:"or,omitempty"`
+}
+
+type TextFilter struct {
+	Equals   string `json:"equals,omitempty"`
+	Contains string `json:"contains,omitempty"`
+}
+
+type NumberFilter struct {
+	Equals               *float64 `json:"equals,omitempty"`
+	GreaterThan          *float64 `json:"greater_than,omitempty"`
+	LessThan             *float64 `json:"less_than,omitempty"`
+	GreaterThanOrEqualTo *float64 `json:"greater_than_or_equal_to,omitempty"`
+	LessThanOrEqualTo    *float64 `json:"less_than_or_equal_to,omitempty"`
+}
+
+// SelectFilter はセレクトならその値か、マルチセレクトならその値を含むか
+type SelectFilter struct {
+	Equals       string `json:"equals,omitempty"`
+	DoesNotEqual string `json:"does_not_equal,omitempty"`
+	Contains     string `json:"contains,omitempty"`
+}
+
+type DateFilter struct {
+	Equals     string `json:"equals,omitempty"`
+	Before     string `json:"before,omitempty"`
+	After      string `json:"after,omitempty"`
+	OnOrBefore string `json:"on_or_before,omitempty"`
+	OnOrAfter  string `json:"on_or_after,omitempty"`
+}
+
+type CheckboxFilter struct {
+	Equals bool `json:"equals"`
+}
+
+// Sort は並び順。Property か Timestamp (created_time など) のどちらかを入れる
+type Sort struct {
+	Property  string `json:"property,omitempty"`
+	Timestamp string `json:"timestamp,omitempty"`
+	Direction string `json:"direction"`
+}
+
+// Query はデータベースの検索条件
+type Query struct {
+	Filter *Filter
+	Sorts  []Sort
+	// PageSize は 1 回のリクエストで取る件数。0 なら Notion の既定 (100)
+	PageSize int
+}
+
+// --- よく使う条件を作る関数 ---
+
+func And(filters ...Filter) *Filter {
+	return &Filter{And: filters}
+}
+
+func Or(filters ...Filter) *Filter {
+	return &Filter{Or: filters}
+}
+
+func SelectEquals(property, name string) Filter {
+	return Filter{Property: property, Select: &SelectFilter{Equals: name}}
+}
+
+// DateOnOrAfter は property が t の日付以降
+func DateOnOrAfter(property string, t time.Time) Filter {
+	return Filter{Property: property, Date: &DateFilter{OnOrAfter: t.Format(dateLayout)}}
+}
+
+// DateBefore は property が t の日付より前
+func DateBefore(property string, t time.Time) Filter {
+	return Filter{Property: property, Date: &DateFilter{Before: t.Format(dateLayout)}}
+}
+
+type queryRequest struct {
+	Filter      *Filter `json:"filter,omitempty"`
+	Sorts       []Sort  `json:"sorts,omitempty"`
+	StartCursor string  `json:"start_cursor,omitempty"`
+	PageSize    int     `json:"page_size,omitempty"`
+}
+
+// PageIterator はクエリの結果を 1 行ずつ返す
+// 1 回のレスポンスに収まらない分は next_cursor をたどって取りにいく
+//
+//	it := c.Query(q)
+//	for it.Next() {
+//		page := it.Page()
+//	}
+//	if err := it.Err(); err != nil { ... }
+type PageIterator struct {
+	c      *Client
+	q      Query
+	buf    []Page
+	page   Page
+	cursor string
+	done   bool
+	err    error
+}
+
+// Query はデータベースを検索するイテレータを返す。リクエストは Next で送る
+func (c *Client) Query(q Query) *PageIterator {
+	return &PageIterator{c: c, q: q}
+}
+
+// Next は次の行に進む。もう行がないかエラーになったら false
+func (it *PageIterator) Next() bool {
+	for len(it.buf) == 0 {
+		if it.done || it.err != nil {
+			return false
+		}
+		it.fetch()
+	}
+	it.page, it.buf = it.buf[0], it.buf[1:]
+	return true
+}
+
+// Page はいまの行を返す
+func (it *PageIterator) Page() Page {
+	return it.page
+}
+
+// Err は途中で起きたエラーを返す
+func (it *PageIterator) Err() error {
+	return it.err
+}
+
+func (it *PageIterator) fetch() {
+	req := queryRequest{
+		Filter:      it.q.Filter,
+		Sorts:       it.q.Sorts,
+		StartCursor: it.cursor,
+		PageSize:    it.q.PageSize,
+	}
+	var resp QueryResponse
+	if err := it.c.do("POST", "/databases/"+it.c.dbID+"/query", req, &resp); err != nil {
+		it.err = err
+		return
+	}
+
+	it.buf = resp.Results
+	if !resp.HasMore || resp.NextCursor == nil || *resp.NextCursor == "" {
+		it.done = true
+		return
+	}
+	it.cursor = *resp.NextCursor
+}
+
+// QueryAll はクエリの結果をすべて返す
+func (c *Client) QueryAll(q Query) ([]Page, error) {
+	var pages []Page
+	it := c.Query(q)
+	for it.Next() {
+		pages = append(pages, it.Page())
+	}
+	return pages, it.Err()
+}
@@ -0,0 +1,43 @@
+package notion
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestQueryFollowsCursor(t *testing.T) {
+	var cursors []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/databases/db/query" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		var req queryRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		cursors = append(cursors, req.StartCursor)
+
+		// 1 回目は 2 件と次のカーソル、2 回目は残りの 1 件
+		if req.StartCursor == "" {
+			fmt.Fprint(w, `{"results":[{"id":"1"},{"id":"2"}],"has_more":true,"next_cursor":"c2"}`)
+			return
+		}
+		fmt.Fprint(w, `{"results":[{"id":"3"}],"has_more":false,"next_cursor":null}`)
+	}))
+	defer srv.Close()
+
+	c := NewClient("key", "db")
+	c.baseURL = srv.URL
+
+	pages, err := c.QueryAll(Query{Filter: And(SelectEquals("カテゴリ", "旅行"))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 3 || pages[2].ID != "3" {
+		t.Fatalf("QueryAll() = %+v", pages)
+	}
+	if len(cursors) != 2 || cursors[1] != "c2" {
+		t.Fatalf("cursors = %v", cursors)
+	}
+}
@@ -0,0 +1,217 @@
+package notion
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// プロパティの型
+const (
+	TypeTitle       = "title"
+	TypeRichText    = "rich_text"
+	TypeNumber      = "number"
+	TypeSelect      = "select"
+	TypeMultiSelect = "multi_select"
+	TypeDate        = "date"
+	TypeFormula     = "formula"
+	TypeRollup      = "rollup"
+)
+
+// Field は家計簿の 1 項目を Notion のどのプロパティに入れるか
+// Name が空の項目は読み書きしない
+type Field struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// Schema は家計簿データベースのプロパティ名と型の対応
+type Schema struct {
+	Title    Field `json:"title"`    // 費目
+	Amount   Field `json:"amount"`   // 一人あたりの支払額
+	People   Field `json:"people"`   // 支払人数
+	Category Field `json:"category"` // カテゴリ
+	Wallet   Field `json:"wallet"`   // 財布
+	Date     Field `json:"date"`     // 支払日時
+	Total    Field `json:"total"`    // 総支払額 (読み取りだけ)
+	Currency Field `json:"currency"` // 外貨の通貨 (設定したときだけ)
+	Original Field `json:"original"` // 外貨の一人あたりの金額 (設定したときだけ)
+}
+
+// DefaultSchema はもともとの家計簿データベースの対応
+// もともとのデータベースには外貨の列がないので、Currency と Original は空にしておく
+func DefaultSchema() Schema {
+	return Schema{
+		Title:    Field{Name: "費目", Type: TypeTitle},
+		Amount:   Field{Name: "一人あたりの支払額", Type: TypeNumber},
+		People:   Field{Name: "支払人数", Type: TypeNumber},
+		Category: Field{Name: "カテゴリ", Type: TypeSelect},
+		Wallet:   Field{Name: "財布", Type: TypeSelect},
+		Date:     Field{Name: "支払日時", Type: TypeDate},
+		Total:    Field{Name: "総支払額", Type: TypeFormula},
+	}
+}
+
+// LoadSchema は JSON ファイルから対応を読む。書かれていない項目は DefaultSchema のまま
+// 外貨の通貨と金額も残したいときは currency と original を書く
+//
+//	{"title": {"name": "Name", "type": "title"}, "currency": {"name": "現地通貨", "type": "select"}, "original": {"name": "現地金額", "type": "number"}}
+func LoadSchema(path string) (Schema, error) {
+	s := DefaultSchema()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return s, fmt.Errorf("failed to read schema: %w", err)
+	}
+	if err := json.Unmarshal(b, &s); err != nil {
+		return s, fmt.Errorf("failed to decode schema: %w", err)
+	}
+	if err := s.validate(); err != nil {
+		return s, err
+	}
+	return s, nil
+}
+
+// fields は項目の名前と対応を並べる
+func (s Schema) fields() []struct {
+	key   string
+	field Field
+	types []string
+} {
+	text := []string{TypeTitle, TypeRichText, TypeSelect, TypeMultiSelect}
+	number := []string{TypeNumber}
+	return []struct {
+		key   string
+		field Field
+		types []string
+	}{
+		{"title", s.Title, text},
+		{"amount", s.Amount, number},
+		{"people", s.People, number},
+		{"category", s.Category, text},
+		{"wallet", s.Wallet, text},
+		{"date", s.Date, []string{TypeDate}},
+		{"total", s.Total, []string{TypeNumber, TypeFormula, TypeRollup}},
+		{"currency", s.Currency, text},
+		{"original", s.Original, number},
+	}
+}
+
+func (s Schema) validate() error {
+	for _, f := range s.fields() {
+		if f.field.Name == "" {
+			continue
+		}
+		if !contains(f.types, f.field.Type) {
+			return fmt.Errorf("schema %s: type %q is not one of %s", f.key, f.field.Type, strings.Join(f.types, ", "))
+		}
+	}
+	if s.Title.Name == "" || s.Title.Type != TypeTitle {
+		return fmt.Errorf("schema title: a title property is required")
+	}
+	return nil
+}
+
+func contains(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// ExpenseProperties は e を書き込み用のプロパティにする
+func (s Schema) ExpenseProperties(e Expense) Properties {
+	props := Properties{}
+	s.Title.setText(props, e.Title)
+	s.Amount.setNumber(props, float64(e.Amount))
+	s.People.setNumber(props, float64(e.People))
+	s.Category.setText(props, e.Category)
+	s.Wallet.setText(props, e.Wallet)
+	if s.Date.Name != "" {
+		props[s.Date.Name] = DateProperty(e.Date)
+	}
+	if e.Currency != "" && e.Currency != "JPY" {
+		s.Currency.setText(props, e.Currency)
+		s.Original.setNumber(props, e.OriginalAmount)
+	}
+	return props
+}
+
+// ExpenseFromPage はデータベースの 1 行を Expense にする
+func (s Schema) ExpenseFromPage(p Page) Expense {
+	e := Expense{
+		Title:          s.Title.text(p),
+		Category:       s.Category.text(p),
+		Wallet:         s.Wallet.text(p),
+		Currency:       s.Currency.text(p),
+		Amount:         int(s.Amount.number(p)),
+		People:         int(s.People.number(p)),
+		OriginalAmount: s.Original.number(p),
+		Total:          int(s.Total.number(p)),
+	}
+	if s.Date.Name != "" {
+		if t, ok := p.Properties[s.Date.Name].DateValue(); ok {
+			e.Date = t
+		}
+	}
+	return e
+}
+
+// Equals は項目が v の行を絞り込む条件
+func (f Field) Equals(v string) Filter {
+	switch f.Type {
+	case TypeTitle:
+		return Filter{Property: f.Name, Title: &TextFilter{Equals: v}}
+	case TypeRichText:
+		return Filter{Property: f.Name, RichText: &TextFilter{Equals: v}}
+	case TypeMultiSelect:
+		return Filter{Property: f.Name, MultiSelect: &SelectFilter{Contains: v}}
+	}
+	return SelectEquals(f.Name, v)
+}
+
+func (f Field) setText(props Properties, v string) {
+	if f.Name == "" {
+		return
+	}
+	switch f.Type {
+	case TypeTitle:
+		props[f.Name] = TitleProperty(v)
+	case TypeRichText:
+		props[f.Name] = RichTextProperty(v)
+	case TypeMultiSelect:
+		props[f.Name] = MultiSelectProperty(v)
+	default:
+		props[f.Name] = SelectProperty(v)
+	}
+}
+
+func (f Field) setNumber(props Properties, v float64) {
+	if f.Name != "" {
+		props[f.Name] = NumberProperty(v)
+	}
+}
+
+func (f Field) text(p Page) string {
+	if f.Name == "" {
+		return ""
+	}
+	prop := p.Properties[f.Name]
+	switch f.Type {
+	case TypeSelect:
+		return prop.SelectName()
+	case TypeMultiSelect:
+		return strings.Join(prop.MultiSelectNames(), ",")
+	}
+	return prop.Text()
+}
+
+func (f Field) number(p Page) float64 {
+	if f.Name == "" {
+		return 0
+	}
+	v, _ := p.Properties[f.Name].NumberValue()
+	return v
+}